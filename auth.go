@@ -0,0 +1,51 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import "net/http"
+
+// AuthorizeFunc decides whether a request may subscribe to a stream. The
+// returned info is attached to the subscriber. Returning an *AuthError
+// rejects the request with its status code, any other error rejects it with
+// 403 Forbidden.
+type AuthorizeFunc func(r *http.Request, streamID string) (SubscriberInfo, error)
+
+// SubscriberInfo holds the identity of an authorized subscriber
+type SubscriberInfo struct {
+	// Identifies the subscriber, e.g. a user id
+	ID string
+	// Claims granted to the subscriber, e.g. taken from its token
+	Claims map[string]interface{}
+}
+
+// AuthError rejects a subscription with a specific status code
+type AuthError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *AuthError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// authorize runs the server's authorization hook for a stream
+func (s *Server) authorize(r *http.Request, streamID string) (SubscriberInfo, int, error) {
+	if s.Authorize == nil {
+		return SubscriberInfo{}, http.StatusOK, nil
+	}
+
+	info, err := s.Authorize(r, streamID)
+	if err != nil {
+		if authErr, ok := err.(*AuthError); ok {
+			return info, authErr.StatusCode, err
+		}
+		return info, http.StatusForbidden, err
+	}
+
+	return info, http.StatusOK, nil
+}
@@ -0,0 +1,75 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestModifier modifies the request of every connection attempt, e.g. to
+// set fresh credentials
+type RequestModifier func(ctx context.Context, req *http.Request) error
+
+// Token is an access token, like oauth2.Token
+type Token struct {
+	AccessToken string
+	// Type of the token, defaults to "Bearer"
+	TokenType string
+	// Time after which the token must be refreshed, never when zero
+	Expiry time.Time
+}
+
+// TokenSource returns access tokens, like oauth2.TokenSource. It is called
+// when the current token expired or was rejected by the server.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+type refreshKey struct{}
+
+// withRefresh marks a context as the one of a request retried because the
+// server rejected its credentials
+func withRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+// RefreshRequested reports whether a RequestModifier is called to retry a
+// request the server answered with 401 Unauthorized, in which case cached
+// credentials should be refreshed.
+func RefreshRequested(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+// TokenRequestModifier sets the Authorization header to a token from the
+// source. The token is cached until it expires or is rejected by the server.
+func TokenRequestModifier(source TokenSource) RequestModifier {
+	var token *Token
+	var mu sync.Mutex
+
+	return func(ctx context.Context, req *http.Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if token == nil || RefreshRequested(ctx) || !token.Expiry.IsZero() && time.Now().After(token.Expiry) {
+			t, err := source.Token()
+			if err != nil {
+				return err
+			}
+			token = t
+		}
+
+		tokenType := token.TokenType
+		if tokenType == "" {
+			tokenType = "Bearer"
+		}
+		req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+
+		return nil
+	}
+}
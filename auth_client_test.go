@@ -0,0 +1,99 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingTokenSource returns a new token on each call
+type countingTokenSource struct {
+	calls  int
+	expiry time.Time
+	mu     sync.Mutex
+}
+
+func (s *countingTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	return &Token{AccessToken: fmt.Sprintf("token-%d", s.calls), Expiry: s.expiry}, nil
+}
+
+func TestTokenRequestModifier(t *testing.T) {
+	source := &countingTokenSource{}
+	modify := TokenRequestModifier(source)
+
+	header := func(ctx context.Context) string {
+		req, _ := http.NewRequest("GET", "http://localhost", nil)
+		require.Nil(t, modify(ctx, req))
+		return req.Header.Get("Authorization")
+	}
+
+	assert.Equal(t, "Bearer token-1", header(context.Background()))
+	assert.Equal(t, "Bearer token-1", header(context.Background()))
+	assert.Equal(t, "Bearer token-2", header(withRefresh(context.Background())))
+
+	// Expired tokens are refreshed
+	source.expiry = time.Now().Add(-time.Second)
+	assert.Equal(t, "Bearer token-3", header(withRefresh(context.Background())))
+	assert.Equal(t, "Bearer token-4", header(context.Background()))
+}
+
+func TestClientUnauthorizedRefresh(t *testing.T) {
+	s := New()
+	defer s.Close()
+	s.CreateStream("test")
+
+	var mu sync.Mutex
+	var authorizations []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.RequestModifier = TokenRequestModifier(&countingTokenSource{})
+
+	events := make(chan *Event)
+	start := time.Now()
+	require.Nil(t, c.SubscribeChan("test", events))
+	defer c.Unsubscribe(events)
+
+	assert.True(t, time.Since(start) < time.Millisecond*500)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, authorizations)
+}
+
+func TestClientRequestModifierError(t *testing.T) {
+	c := NewClient("http://localhost")
+	c.RequestModifier = func(ctx context.Context, req *http.Request) error {
+		return fmt.Errorf("no credentials")
+	}
+
+	_, err := c.request(context.Background(), "test")
+	assert.EqualError(t, err, "no credentials")
+}
@@ -0,0 +1,136 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthServer(authorize AuthorizeFunc) (*Server, *httptest.Server) {
+	s := New()
+	s.Authorize = authorize
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.ServeHTTP)
+	server := httptest.NewServer(mux)
+
+	s.CreateStream("test")
+
+	return s, server
+}
+
+func TestAuthorizeReject(t *testing.T) {
+	s, server := newAuthServer(func(r *http.Request, streamID string) (SubscriberInfo, error) {
+		if r.Header.Get("Authorization") == "" {
+			return SubscriberInfo{}, &AuthError{StatusCode: http.StatusUnauthorized}
+		}
+		return SubscriberInfo{}, errors.New("stream not allowed")
+	})
+	defer s.Close()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?stream=test")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, _ := http.NewRequest("GET", server.URL+"/events?stream=test", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	assert.Equal(t, 0, s.getStream("test").getSubscriberCount())
+}
+
+func TestAuthorizeSubscriberInfo(t *testing.T) {
+	s, server := newAuthServer(func(r *http.Request, streamID string) (SubscriberInfo, error) {
+		return SubscriberInfo{ID: "user-1", Claims: map[string]interface{}{"stream": streamID}}, nil
+	})
+	defer s.Close()
+	defer server.Close()
+
+	subscribed := make(chan *Subscriber, 1)
+	s.getStream("test").OnSubscribe = func(streamID string, sub *Subscriber) {
+		subscribed <- sub
+	}
+
+	resp, err := http.Get(server.URL + "/events?stream=test")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case sub := <-subscribed:
+		assert.Equal(t, "user-1", sub.Info.ID)
+		assert.Equal(t, "test", sub.Info.Claims["stream"])
+	case <-time.After(time.Second):
+		t.Fatal("subscriber was not registered")
+	}
+}
+
+func TestAuthorizeInterval(t *testing.T) {
+	var expired int32
+
+	s, server := newAuthServer(func(r *http.Request, streamID string) (SubscriberInfo, error) {
+		if atomic.LoadInt32(&expired) == 1 {
+			return SubscriberInfo{}, errors.New("token expired")
+		}
+		return SubscriberInfo{}, nil
+	})
+	defer s.Close()
+	defer server.Close()
+
+	s.AuthorizeInterval = time.Millisecond * 50
+
+	resp, err := http.Get(server.URL + "/events?stream=test")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	time.Sleep(time.Millisecond * 100)
+	atomic.StoreInt32(&expired, 1)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, ": token expired\n\n", string(body))
+}
+
+func TestAuthorizeIntervalPattern(t *testing.T) {
+	var expired int32
+
+	s, server := newAuthServer(func(r *http.Request, streamID string) (SubscriberInfo, error) {
+		// Only the matched stream is revoked, not the pattern
+		if streamID == "orders.eu" && atomic.LoadInt32(&expired) == 1 {
+			return SubscriberInfo{}, errors.New("access to orders.eu\nrevoked")
+		}
+		return SubscriberInfo{}, nil
+	})
+	defer s.Close()
+	defer server.Close()
+
+	s.AuthorizeInterval = time.Millisecond * 50
+	s.CreateStream("orders.eu")
+
+	resp, err := http.Get(server.URL + "/events?stream=orders.*")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	time.Sleep(time.Millisecond * 100)
+	atomic.StoreInt32(&expired, 1)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, ": access to orders.eu revoked\n\n", string(body))
+}
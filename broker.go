@@ -0,0 +1,84 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import "sync"
+
+// Broker distributes the events published to a stream to every server
+// instance that has the stream, so that subscribers receive them no matter
+// which instance they are connected to.
+type Broker interface {
+	// Publish sends an event to the stream on every instance
+	Publish(streamID string, ev *Event) error
+	// Subscribe calls handler with every event published to the stream by any
+	// instance, until the returned function is called
+	Subscribe(streamID string, handler func(ev *Event)) (func(), error)
+	// EventStore returns the event log of the stream shared by all instances.
+	// Brokers without a shared log return nil, each instance then logs the
+	// events it receives. Events stored in a shared log are assigned their id
+	// by the broker when published.
+	EventStore(streamID string) EventStore
+}
+
+// MemoryBroker is an in-process Broker, shared by servers running in the
+// same process
+type MemoryBroker struct {
+	handlers map[string]map[int]func(ev *Event)
+	next     int
+	mu       sync.RWMutex
+}
+
+// NewMemoryBroker creates an in-process broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		handlers: make(map[string]map[int]func(ev *Event)),
+	}
+}
+
+// Publish hands a copy of the event to every subscriber of the stream
+func (b *MemoryBroker) Publish(streamID string, ev *Event) error {
+	b.mu.RLock()
+	handlers := make([]func(ev *Event), 0, len(b.handlers[streamID]))
+	for _, handler := range b.handlers[streamID] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		cp := *ev
+		handler(&cp)
+	}
+
+	return nil
+}
+
+// Subscribe registers a handler for the events published to the stream
+func (b *MemoryBroker) Subscribe(streamID string, handler func(ev *Event)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.handlers[streamID] == nil {
+		b.handlers[streamID] = make(map[int]func(ev *Event))
+	}
+
+	id := b.next
+	b.next++
+	b.handlers[streamID][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.handlers[streamID], id)
+		if len(b.handlers[streamID]) == 0 {
+			delete(b.handlers, streamID)
+		}
+	}, nil
+}
+
+// EventStore returns nil, every server keeps its own event log
+func (b *MemoryBroker) EventStore(streamID string) EventStore {
+	return nil
+}
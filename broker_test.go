@@ -0,0 +1,120 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+
+	received := make(chan *Event, 2)
+	unsubscribe, err := b.Subscribe("test", func(ev *Event) {
+		received <- ev
+	})
+	require.Nil(t, err)
+
+	ev := &Event{Data: []byte("test")}
+	require.Nil(t, b.Publish("test", ev))
+	require.Nil(t, b.Publish("other", &Event{Data: []byte("other")}))
+
+	msg, err := wait(received, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), msg)
+	assert.Equal(t, 0, len(received))
+
+	unsubscribe()
+	require.Nil(t, b.Publish("test", ev))
+	assert.Equal(t, 0, len(received))
+	assert.Nil(t, b.EventStore("test"))
+}
+
+func TestServerMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+
+	s1 := New()
+	s1.Broker = b
+	defer s1.Close()
+
+	s2 := New()
+	s2.Broker = b
+	defer s2.Close()
+
+	s1.CreateStream("test")
+	s2.CreateStream("test")
+
+	sub1 := s1.getStream("test").addSubscriber("", nil)
+	sub2 := s2.getStream("test").addSubscriber("", nil)
+
+	s1.Publish("test", &Event{Data: []byte("test 1")})
+	s2.Publish("test", &Event{Data: []byte("test 2")})
+
+	for _, sub := range []*Subscriber{sub1, sub2} {
+		msg, err := wait(sub.connection, time.Second)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("test 1"), msg)
+
+		msg, err = wait(sub.connection, time.Second)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("test 2"), msg)
+	}
+}
+
+// failingBroker is a memory broker whose first subscription fails
+type failingBroker struct {
+	*MemoryBroker
+	failed int32
+}
+
+var errSubscribeFailed = errors.New("subscribe failed")
+
+func (b *failingBroker) Subscribe(streamID string, handler func(ev *Event)) (func(), error) {
+	if atomic.CompareAndSwapInt32(&b.failed, 0, 1) {
+		return nil, errSubscribeFailed
+	}
+	return b.MemoryBroker.Subscribe(streamID, handler)
+}
+
+func TestServerBrokerSubscribeError(t *testing.T) {
+	b := &failingBroker{MemoryBroker: NewMemoryBroker()}
+
+	errs := make(chan error, 1)
+
+	s := New()
+	s.Broker = b
+	s.OnError = func(streamID string, err error) {
+		errs <- err
+	}
+	defer s.Close()
+
+	sub := s.CreateStream("test").addSubscriber("", nil)
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, errSubscribeFailed, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription error to be reported")
+	}
+
+	// The subscription is retried
+	require.Eventually(t, func() bool {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(b.handlers["test"]) == 1
+	}, time.Second*2, time.Millisecond*10)
+
+	s.Publish("test", &Event{Data: []byte("test")})
+
+	msg, err := wait(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), msg)
+}
@@ -12,6 +12,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,21 +22,46 @@ import (
 )
 
 var (
-	headerID    = []byte("id:")
-	headerData  = []byte("data:")
-	headerEvent = []byte("event:")
-	headerRetry = []byte("retry:")
+	headerID     = []byte("id:")
+	headerData   = []byte("data:")
+	headerEvent  = []byte("event:")
+	headerRetry  = []byte("retry:")
+	headerStream = []byte("stream:")
+	headerTrace  = []byte("trace:")
 )
 
+// ErrHeartbeatTimeout is returned when the server sent nothing, not even a
+// keepalive comment, within the client's heartbeat timeout
+var ErrHeartbeatTimeout = errors.New("no data received from server within heartbeat timeout")
+
+// ClientMaxBufferSize sets the maximum size of the events read, 64KiB by
+// default. Larger events are skipped and reported to the OnError callback.
+// Their size is not limited when s is zero or negative.
 func ClientMaxBufferSize(s int) func(c *Client) {
 	return func(c *Client) {
 		c.maxBufferSize = s
 	}
 }
 
+// ClientHeartbeatTimeout treats a connection on which nothing was received for
+// the given duration as dead, and reconnects
+func ClientHeartbeatTimeout(d time.Duration) func(c *Client) {
+	return func(c *Client) {
+		c.heartbeatTimeout = d
+	}
+}
+
 // ConnCallback defines a function to be called on a particular connection event
 type ConnCallback func(c *Client)
 
+// ErrorCallback defines a function to be called with the errors that do not
+// end the connection
+type ErrorCallback func(err error)
+
+// CommentCallback defines a function to be called with the comments sent by
+// the server
+type CommentCallback func(comment []byte)
+
 // ResponseValidator validates a response
 type ResponseValidator func(c *Client, resp *http.Response) error
 
@@ -48,13 +75,37 @@ type Client struct {
 	Headers           map[string]string
 	ReconnectNotify   backoff.Notify
 	ResponseValidator ResponseValidator
+	RequestModifier   RequestModifier
+	StreamURLBuilder  StreamURLBuilder
 	Connection        *http.Client
 	URL               string
 	LastEventID       atomic.Value // []byte
 	maxBufferSize     int
+	heartbeatTimeout  time.Duration
 	mu                sync.Mutex
 	EncodingBase64    bool
-	Connected         bool
+	// Deprecated: use State instead
+	Connected bool
+	// Ignores the reconnection time sent by the server in retry: fields
+	IgnoreServerRetry bool
+	serverRetry       int64
+	// Delay requested by the last Retry-After header, in nanoseconds
+	retryAfter int64
+	// Starts a consumer span around each handler call
+	Tracer Tracer
+	// Handlers registered with On, OnMessage and OnAny
+	handlers    []eventHandler
+	nextHandler int
+	// Callback registered with OnComment
+	commentcb  CommentCallback
+	errorcb    ErrorCallback
+	muHandlers sync.RWMutex
+	// Ids of the events received, see ClientDeduplicate and OnGap
+	sequence eventSequence
+	// Connection state, see State
+	state   int32
+	statecb StateCallback
+	muState sync.Mutex
 }
 
 // NewClient creates a new client
@@ -81,42 +132,59 @@ func (c *Client) Subscribe(stream string, handler func(msg *Event)) error {
 
 // SubscribeWithContext to a data stream with context
 func (c *Client) SubscribeWithContext(ctx context.Context, stream string, handler func(msg *Event)) error {
+	return c.subscribe(ctx, handler, stream)
+}
+
+// SubscribeMulti to several data streams over a single connection
+func (c *Client) SubscribeMulti(streams []string, handler func(msg *Event)) error {
+	return c.SubscribeMultiWithContext(context.Background(), streams, handler)
+}
+
+// SubscribeMultiWithContext to several data streams over a single connection
+// with context. The Stream field of each event names its source stream, and
+// its ID is the event id within that stream.
+func (c *Client) SubscribeMultiWithContext(ctx context.Context, streams []string, handler func(msg *Event)) error {
+	return c.subscribe(ctx, func(msg *Event) {
+		if len(msg.Stream) > 0 {
+			ids, _ := url.ParseQuery(string(msg.ID))
+			msg.ID = []byte(ids.Get(string(msg.Stream)))
+		}
+		handler(msg)
+	}, streams...)
+}
+
+func (c *Client) subscribe(ctx context.Context, handler func(msg *Event), streams ...string) error {
+	c.setState(StateConnecting, nil)
+
 	operation := func() error {
-		resp, err := c.request(ctx, stream)
+		resp, err := c.connect(ctx, streams...)
 		if err != nil {
-			return err
-		}
-		if validator := c.ResponseValidator; validator != nil {
-			err = validator(c, resp)
-			if err != nil {
-				return err
-			}
-		} else if resp.StatusCode != 200 {
-			resp.Body.Close()
-			return fmt.Errorf("could not connect to stream: %s", http.StatusText(resp.StatusCode))
+			return c.retryable(ctx, err)
 		}
 		defer resp.Body.Close()
 
+		c.setState(StateOpen, nil)
+
 		reader := NewEventStreamReader(resp.Body, c.maxBufferSize)
 		eventChan, errorChan := c.startReadLoop(reader)
 
 		for {
 			select {
 			case err = <-errorChan:
-				return err
+				return c.retryable(ctx, err)
 			case msg := <-eventChan:
-				handler(msg)
+				stream := string(msg.Stream)
+				if stream == "" && len(streams) == 1 {
+					stream = streams[0]
+				}
+				c.traceReceive(handler, stream, msg)
 			}
 		}
 	}
 
 	// Apply user specified reconnection strategy or default to standard NewExponentialBackOff() reconnection method
-	var err error
-	if c.ReconnectStrategy != nil {
-		err = backoff.RetryNotify(operation, c.ReconnectStrategy, c.ReconnectNotify)
-	} else {
-		err = backoff.RetryNotify(operation, backoff.NewExponentialBackOff(), c.ReconnectNotify)
-	}
+	err := c.retry(ctx, operation)
+	c.setState(StateClosed, err)
 	return err
 }
 
@@ -133,22 +201,17 @@ func (c *Client) SubscribeChanWithContext(ctx context.Context, stream string, ch
 	c.subscribed[ch] = make(chan struct{})
 	c.mu.Unlock()
 
+	c.setState(StateConnecting, nil)
+
 	operation := func() error {
-		resp, err := c.request(ctx, stream)
+		resp, err := c.connect(ctx, stream)
 		if err != nil {
-			return err
-		}
-		if validator := c.ResponseValidator; validator != nil {
-			err = validator(c, resp)
-			if err != nil {
-				return err
-			}
-		} else if resp.StatusCode != 200 {
-			resp.Body.Close()
-			return fmt.Errorf("could not connect to stream: %s", http.StatusText(resp.StatusCode))
+			return c.retryable(ctx, err)
 		}
 		defer resp.Body.Close()
 
+		c.setState(StateOpen, nil)
+
 		if !connected {
 			// Notify connect
 			errch <- nil
@@ -165,7 +228,7 @@ func (c *Client) SubscribeChanWithContext(ctx context.Context, stream string, ch
 			case <-c.subscribed[ch]:
 				return nil
 			case err = <-errorChan:
-				return err
+				return c.retryable(ctx, err)
 			case msg = <-eventChan:
 			}
 
@@ -184,12 +247,8 @@ func (c *Client) SubscribeChanWithContext(ctx context.Context, stream string, ch
 	go func() {
 		defer c.cleanup(ch)
 		// Apply user specified reconnection strategy or default to standard NewExponentialBackOff() reconnection method
-		var err error
-		if c.ReconnectStrategy != nil {
-			err = backoff.RetryNotify(operation, c.ReconnectStrategy, c.ReconnectNotify)
-		} else {
-			err = backoff.RetryNotify(operation, backoff.NewExponentialBackOff(), c.ReconnectNotify)
-		}
+		err := c.retry(ctx, operation)
+		c.setState(StateClosed, err)
 
 		// channel closed once connected
 		if err != nil && !connected {
@@ -201,6 +260,34 @@ func (c *Client) SubscribeChanWithContext(ctx context.Context, stream string, ch
 	return err
 }
 
+// connect requests the streams and validates the response
+func (c *Client) connect(ctx context.Context, streams ...string) (*http.Response, error) {
+	resp, err := c.request(ctx, streams...)
+	if err != nil {
+		return nil, err
+	}
+	// Retry once with refreshed credentials
+	if resp.StatusCode == http.StatusUnauthorized && c.RequestModifier != nil {
+		resp.Body.Close()
+		resp, err = c.request(withRefresh(ctx), streams...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if validator := c.ResponseValidator; validator != nil {
+		err = validator(c, resp)
+		if err != nil {
+			return nil, err
+		}
+	} else if err = c.validateResponse(resp); err != nil {
+		return nil, err
+	}
+	if c.heartbeatTimeout > 0 {
+		resp.Body = newWatchdogReader(resp.Body, c.heartbeatTimeout)
+	}
+	return resp, nil
+}
+
 func (c *Client) startReadLoop(reader *EventStreamReader) (chan *Event, chan error) {
 	outCh := make(chan *Event)
 	erChan := make(chan error)
@@ -212,40 +299,59 @@ func (c *Client) readLoop(reader *EventStreamReader, outCh chan *Event, erChan c
 	for {
 		// Read each new line and process the type of event
 		event, err := reader.ReadEvent()
+		if err == ErrEventTooLarge {
+			c.reportError(err)
+			continue
+		}
 		if err != nil {
 			if err == io.EOF {
 				erChan <- nil
 				return
 			}
-			// run user specified disconnect function
-			if c.disconnectcb != nil {
-				c.Connected = false
-				c.disconnectcb(c)
-			}
 			erChan <- err
 			return
 		}
 
-		if !c.Connected && c.connectedcb != nil {
-			c.Connected = true
-			c.connectedcb(c)
+		// Send downstream if the event has something useful
+		if msg := c.parseEvent(event); msg != nil {
+			outCh <- msg
 		}
+	}
+}
 
-		// If we get an error, ignore it.
-		var msg *Event
-		if msg, err = c.processEvent(event); err == nil {
-			if len(msg.ID) > 0 {
-				c.LastEventID.Store(msg.ID)
-			} else {
-				msg.ID, _ = c.LastEventID.Load().([]byte)
-			}
+// parseEvent processes a raw event, keeping track of the server's retry
+// time and of the last event id. It returns nil if the event is invalid or
+// has nothing useful.
+func (c *Client) parseEvent(event []byte) *Event {
+	// If we get an error, ignore it.
+	msg, err := c.processEvent(event)
+	if err != nil {
+		return nil
+	}
 
-			// Send downstream if the event has something useful
-			if msg.hasContent() {
-				outCh <- msg
-			}
+	if msg.Comment != nil {
+		if fn := c.commentCallback(); fn != nil {
+			fn(msg.Comment)
 		}
 	}
+
+	if len(msg.Retry) > 0 {
+		c.setServerRetry(msg.Retry)
+	}
+
+	// Events without id keep the last event id, an empty id resets it
+	hasID := len(msg.ID) > 0
+	if msg.ID != nil {
+		c.LastEventID.Store(msg.ID)
+	} else {
+		msg.ID, _ = c.LastEventID.Load().([]byte)
+	}
+
+	// Only events with data are dispatched, once
+	if msg.Data == nil || hasID && !c.sequence.receive(msg.ID) {
+		return nil
+	}
+	return msg
 }
 
 // SubscribeRaw to an sse endpoint
@@ -288,26 +394,140 @@ func (c *Client) OnConnect(fn ConnCallback) {
 	c.connectedcb = fn
 }
 
-func (c *Client) request(ctx context.Context, stream string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", c.URL, nil)
+// OnComment specifies the function to run with the comment lines of each
+// frame, such as keepalives, joined with "\n". Frames made only of comments
+// are still not dispatched as events, the comments of other frames are also
+// set on their event.
+func (c *Client) OnComment(fn CommentCallback) {
+	c.muHandlers.Lock()
+	defer c.muHandlers.Unlock()
+
+	c.commentcb = fn
+}
+
+// OnError specifies the function to run with the errors that do not end the
+// connection, such as ErrEventTooLarge when an event is skipped
+func (c *Client) OnError(fn ErrorCallback) {
+	c.muHandlers.Lock()
+	defer c.muHandlers.Unlock()
+
+	c.errorcb = fn
+}
+
+// reportError passes an error to the OnError callback, if any
+func (c *Client) reportError(err error) {
+	c.muHandlers.RLock()
+	fn := c.errorcb
+	c.muHandlers.RUnlock()
+
+	if fn != nil {
+		fn(err)
+	}
+}
+
+func (c *Client) commentCallback() CommentCallback {
+	c.muHandlers.RLock()
+	defer c.muHandlers.RUnlock()
+
+	return c.commentcb
+}
+
+// reconnectStrategy returns the user specified reconnection strategy or
+// defaults to standard NewExponentialBackOff() reconnection method. The
+// delay of a Retry-After header and, unless disabled, the reconnection time
+// sent by the server are used as a floor.
+func (c *Client) reconnectStrategy() backoff.BackOff {
+	var strategy backoff.BackOff = backoff.NewExponentialBackOff()
+	if c.ReconnectStrategy != nil {
+		strategy = c.ReconnectStrategy
+	}
+
+	return &serverRetryBackOff{BackOff: strategy, client: c}
+}
+
+// retry runs operation with the reconnection strategy until it succeeds,
+// fails permanently or ctx is done, in which case the context error is
+// returned
+func (c *Client) retry(ctx context.Context, operation backoff.Operation) error {
+	err := backoff.RetryNotify(operation, backoff.WithContext(c.reconnectStrategy(), ctx), c.reconnectNotify())
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// retryable stops further reconnection attempts once ctx is done, so the
+// subscription ends with the context error
+func (c *Client) retryable(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return backoff.Permanent(ctx.Err())
+	}
+	return err
+}
+
+// setServerRetry stores the reconnection time in milliseconds sent by the server
+func (c *Client) setServerRetry(retry []byte) {
+	ms, err := strconv.ParseInt(string(retry), 10, 64)
+	if err != nil || ms < 0 {
+		return
+	}
+	atomic.StoreInt64(&c.serverRetry, ms)
+}
+
+// serverRetryBackOff waits at least the delay requested by the server
+type serverRetryBackOff struct {
+	backoff.BackOff
+	client *Client
+}
+
+func (b *serverRetryBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	if next == backoff.Stop {
+		return next
+	}
+
+	if retryAfter := time.Duration(atomic.SwapInt64(&b.client.retryAfter, 0)); next < retryAfter {
+		next = retryAfter
+	}
+
+	if b.client.IgnoreServerRetry {
+		return next
+	}
+
+	retry := time.Duration(atomic.LoadInt64(&b.client.serverRetry)) * time.Millisecond
+	if next < retry {
+		return retry
+	}
+	return next
+}
+
+func (c *Client) request(ctx context.Context, streams ...string) (*http.Response, error) {
+	// Setup request, specify streams to connect to
+	streamURL := c.URL
+	for _, stream := range streams {
+		if stream == "" {
+			continue
+		}
+
+		var err error
+		streamURL, err = c.streamURL(streamURL, stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest("GET", streamURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req = req.WithContext(ctx)
 
-	// Setup request, specify stream to connect to
-	if stream != "" {
-		query := req.URL.Query()
-		query.Add("stream", stream)
-		req.URL.RawQuery = query.Encode()
-	}
-
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Connection", "keep-alive")
 
 	lastID, exists := c.LastEventID.Load().([]byte)
-	if exists && lastID != nil {
+	if exists && len(lastID) > 0 {
 		req.Header.Set("Last-Event-ID", string(lastID))
 	}
 
@@ -316,9 +536,26 @@ func (c *Client) request(ctx context.Context, stream string) (*http.Response, er
 		req.Header.Set(k, v)
 	}
 
+	if c.RequestModifier != nil {
+		if err := c.RequestModifier(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
 	return c.Connection.Do(req)
 }
 
+func (c *Client) streamURL(baseURL, stream string) (string, error) {
+	if c.StreamURLBuilder != nil {
+		return c.StreamURLBuilder(baseURL, stream)
+	}
+	return QueryStreamURLBuilder(DefaultStreamParam)(baseURL, stream)
+}
+
+// processEvent interprets the lines of an event as described by the
+// EventSource specification. The ID is nil when the event has no id field,
+// and empty when the field resets the last event id. The Data is nil when
+// the event has no data field, in which case it must not be dispatched.
 func (c *Client) processEvent(msg []byte) (event *Event, err error) {
 	var e Event
 
@@ -326,27 +563,68 @@ func (c *Client) processEvent(msg []byte) (event *Event, err error) {
 		return nil, errors.New("event message was empty")
 	}
 
-	// Normalize the crlf to lf to make it easier to split the lines.
-	// Split the line by "\n" or "\r", per the spec.
-	for _, line := range bytes.FieldsFunc(msg, func(r rune) bool { return r == '\n' || r == '\r' }) {
+	for len(msg) > 0 {
+		// Lines end with a crlf, a lone lf or a lone cr
+		line := msg
+		msg = nil
+		if i := bytes.IndexAny(line, "\r\n"); i >= 0 {
+			msg = line[i+1:]
+			if line[i] == '\r' && len(msg) > 0 && msg[0] == '\n' {
+				msg = msg[1:]
+			}
+			line = line[:i]
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		// Comments are joined with "\n"
+		if line[0] == ':' {
+			if e.Comment == nil {
+				e.Comment = []byte{}
+			} else {
+				e.Comment = append(e.Comment, '\n')
+			}
+			e.Comment = append(e.Comment, trimHeader(1, line)...)
+			continue
+		}
+
+		// A line without colon is a field with an empty value
+		name, value := line, []byte{}
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			name, value = line[:i], trimHeader(i+1, line)
+		}
+
 		switch {
-		case bytes.HasPrefix(line, headerID):
-			e.ID = append([]byte(nil), trimHeader(len(headerID), line)...)
-		case bytes.HasPrefix(line, headerData):
+		case isField(name, headerEvent):
+			e.Event = append([]byte{}, value...)
+		case isField(name, headerData):
 			// The spec allows for multiple data fields per event, concatenated them with "\n".
-			e.Data = append(e.Data[:], append(trimHeader(len(headerData), line), byte('\n'))...)
-		// The spec says that a line that simply contains the string "data" should be treated as a data field with an empty body.
-		case bytes.Equal(line, bytes.TrimSuffix(headerData, []byte(":"))):
-			e.Data = append(e.Data, byte('\n'))
-		case bytes.HasPrefix(line, headerEvent):
-			e.Event = append([]byte(nil), trimHeader(len(headerEvent), line)...)
-		case bytes.HasPrefix(line, headerRetry):
-			e.Retry = append([]byte(nil), trimHeader(len(headerRetry), line)...)
+			e.Data = append(append(e.Data, value...), '\n')
+		case isField(name, headerID):
+			// Ids containing NUL are ignored
+			if bytes.IndexByte(value, 0) < 0 {
+				e.ID = append([]byte{}, value...)
+			}
+		case isField(name, headerRetry):
+			// Reconnection times must only be made of ASCII digits
+			if isDigits(value) {
+				e.Retry = append([]byte{}, value...)
+			}
+		case isField(name, headerStream):
+			e.Stream = append([]byte{}, value...)
+		case isField(name, headerTrace):
+			e.TraceContext = append([]byte{}, value...)
 		default:
 			// Ignore any garbage that doesn't match what we're looking for.
 		}
 	}
 
+	if e.Data == nil {
+		return &e, nil
+	}
+
 	// Trim the last "\n" per the spec.
 	e.Data = bytes.TrimSuffix(e.Data, []byte("\n"))
 
@@ -362,6 +640,23 @@ func (c *Client) processEvent(msg []byte) (event *Event, err error) {
 	return &e, err
 }
 
+// isField reports whether a field name is the one of a header
+func isField(name, header []byte) bool {
+	return bytes.Equal(name, header[:len(header)-1])
+}
+
+func isDigits(value []byte) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for _, b := range value {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Client) cleanup(ch chan *Event) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -372,6 +667,43 @@ func (c *Client) cleanup(ch chan *Event) {
 	}
 }
 
+// watchdogReader closes the wrapped body when no data was read from it
+// within the timeout
+type watchdogReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func newWatchdogReader(body io.ReadCloser, timeout time.Duration) *watchdogReader {
+	w := &watchdogReader{
+		body:    body,
+		timeout: timeout,
+	}
+	w.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&w.expired, 1)
+		w.body.Close()
+	})
+	return w
+}
+
+func (w *watchdogReader) Read(p []byte) (int, error) {
+	n, err := w.body.Read(p)
+	if atomic.LoadInt32(&w.expired) == 1 {
+		return n, ErrHeartbeatTimeout
+	}
+	if n > 0 {
+		w.timer.Reset(w.timeout)
+	}
+	return n, err
+}
+
+func (w *watchdogReader) Close() error {
+	w.timer.Stop()
+	return w.body.Close()
+}
+
 func trimHeader(size int, data []byte) []byte {
 	if data == nil || len(data) < size {
 		return data
@@ -5,12 +5,14 @@
 package sse
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -355,6 +357,57 @@ func TestClientLargeData(t *testing.T) {
 	require.Equal(t, data, d)
 }
 
+func TestClientUnboundedData(t *testing.T) {
+	srv = newServer()
+	defer cleanup()
+
+	c := NewClient(urlPath, ClientMaxBufferSize(0))
+
+	data := bytes.Repeat([]byte("x"), 1<<20)
+
+	ec := make(chan *Event, 1)
+
+	srv.Publish("test", &Event{Data: data})
+
+	go func() {
+		c.Subscribe("test", func(ev *Event) {
+			ec <- ev
+		})
+	}()
+
+	d, err := wait(ec, time.Second)
+	require.Nil(t, err)
+	require.Equal(t, data, d)
+}
+
+func TestClientSkipsTooLargeData(t *testing.T) {
+	srv = newServer()
+	defer cleanup()
+
+	c := NewClient(urlPath, ClientMaxBufferSize(1<<10))
+
+	errs := make(chan error, 1)
+	c.OnError(func(err error) {
+		errs <- err
+	})
+
+	ec := make(chan *Event, 2)
+
+	srv.Publish("test", &Event{Data: bytes.Repeat([]byte("x"), 1<<11)})
+	srv.Publish("test", &Event{Data: []byte("small")})
+
+	go func() {
+		c.Subscribe("test", func(ev *Event) {
+			ec <- ev
+		})
+	}()
+
+	d, err := wait(ec, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("small"), d)
+	assert.Equal(t, ErrEventTooLarge, <-errs)
+}
+
 func TestClientComment(t *testing.T) {
 	srv = newServer()
 	defer cleanup()
@@ -375,6 +428,216 @@ func TestClientComment(t *testing.T) {
 	c.Unsubscribe(events)
 }
 
+func TestClientOnComment(t *testing.T) {
+	srv = newServer()
+	defer cleanup()
+
+	c := NewClient(urlPath)
+
+	comments := make(chan []byte, 2)
+	c.OnComment(func(comment []byte) {
+		comments <- comment
+	})
+
+	events := make(chan *Event)
+	err := c.SubscribeChan("test", events)
+	require.Nil(t, err)
+
+	srv.Publish("test", &Event{Comment: []byte("comment")})
+	srv.Publish("test", &Event{Data: []byte("test"), Comment: []byte("progress")})
+
+	// Frames made only of comments are not dispatched as events
+	ev, err := waitEvent(events, time.Second*1)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), ev.Data)
+	assert.Equal(t, []byte("progress"), ev.Comment)
+
+	assert.Equal(t, []byte("comment"), <-comments)
+	assert.Equal(t, []byte("progress"), <-comments)
+
+	c.Unsubscribe(events)
+}
+
+func TestClientHeartbeatTimeout(t *testing.T) {
+	srv = New()
+	defer srv.Close()
+
+	var connections int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+		srv.ServeHTTP(w, r)
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	srv.CreateStream("test")
+
+	c := NewClient(server.URL+"/events", ClientHeartbeatTimeout(time.Millisecond*100))
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Millisecond * 10)
+
+	notified := make(chan error, 1)
+	c.ReconnectNotify = func(err error, d time.Duration) {
+		select {
+		case notified <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.SubscribeWithContext(ctx, "test", func(msg *Event) {})
+
+	select {
+	case err := <-notified:
+		assert.Equal(t, ErrHeartbeatTimeout, err)
+	case <-time.After(time.Second):
+		t.Fatal("connection without heartbeats was not dropped")
+	}
+
+	for i := 0; i < 100 && atomic.LoadInt32(&connections) < 2; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.True(t, atomic.LoadInt32(&connections) > 1)
+}
+
+func TestClientHeartbeatKeepAlive(t *testing.T) {
+	srv = newServer()
+	srv.getStream("test").HeartbeatInterval = time.Millisecond * 20
+	defer cleanup()
+
+	c := NewClient(urlPath, ClientHeartbeatTimeout(time.Millisecond*100))
+	c.ReconnectNotify = func(err error, d time.Duration) {
+		t.Errorf("unexpected reconnect: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*300, cancel)
+
+	_ = c.SubscribeWithContext(ctx, "test", func(msg *Event) {})
+}
+
+func TestClientCancelDuringReconnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ReconnectNotify = func(err error, d time.Duration) {
+		cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.SubscribeWithContext(ctx, "test", func(msg *Event) {})
+	}()
+
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("subscription kept reconnecting after the context was cancelled")
+	}
+
+	events := make(chan *Event)
+	ctx, cancel = context.WithCancel(context.Background())
+	c.ReconnectNotify = func(err error, d time.Duration) {
+		cancel()
+	}
+
+	go func() {
+		done <- c.SubscribeChanWithContext(ctx, "test", events)
+	}()
+
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("channel subscription kept reconnecting after the context was cancelled")
+	}
+}
+
+func TestClientServerRetry(t *testing.T) {
+	srv = newServer()
+	defer cleanup()
+
+	c := NewClient(urlPath)
+
+	events := make(chan *Event)
+	err := c.SubscribeChan("test", events)
+	require.Nil(t, err)
+	defer c.Unsubscribe(events)
+
+	srv.Publish("test", &Event{Data: []byte("ping"), Retry: []byte("1500")})
+
+	msg, err := waitEvent(events, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("1500"), msg.Retry)
+	assert.Equal(t, int64(1500), atomic.LoadInt64(&c.serverRetry))
+}
+
+func TestClientServerRetryBackOff(t *testing.T) {
+	c := NewClient(urlPath)
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Millisecond * 100)
+
+	assert.Equal(t, time.Millisecond*100, c.reconnectStrategy().NextBackOff())
+
+	c.setServerRetry([]byte("3000"))
+	assert.Equal(t, time.Second*3, c.reconnectStrategy().NextBackOff())
+
+	c.setServerRetry([]byte("invalid"))
+	assert.Equal(t, time.Second*3, c.reconnectStrategy().NextBackOff())
+
+	c.setServerRetry([]byte("50"))
+	assert.Equal(t, time.Millisecond*100, c.reconnectStrategy().NextBackOff())
+
+	c.setServerRetry([]byte("3000"))
+	c.IgnoreServerRetry = true
+	assert.Equal(t, time.Millisecond*100, c.reconnectStrategy().NextBackOff())
+
+	c.IgnoreServerRetry = false
+	c.ReconnectStrategy = &backoff.StopBackOff{}
+	assert.Equal(t, backoff.Stop, c.reconnectStrategy().NextBackOff())
+}
+
+func TestClientSubscribeMulti(t *testing.T) {
+	srv = newServer()
+	defer cleanup()
+
+	srv.CreateStream("other")
+
+	c := NewClient(urlPath)
+
+	events := make(chan *Event)
+	go func() {
+		_ = c.SubscribeMulti([]string{"test", "other"}, func(msg *Event) {
+			events <- msg
+		})
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	srv.Publish("test", &Event{Data: []byte("test 1")})
+	msg, err := waitEvent(events, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), msg.Stream)
+	assert.Equal(t, []byte("0"), msg.ID)
+
+	srv.Publish("other", &Event{Data: []byte("other 1")})
+	msg, err = waitEvent(events, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("other"), msg.Stream)
+	assert.Equal(t, []byte("0"), msg.ID)
+	assert.Equal(t, []byte("other 1"), msg.Data)
+
+	lastID, _ := c.LastEventID.Load().([]byte)
+	assert.Equal(t, []byte("other=0&test=0"), lastID)
+}
+
 func TestTrimHeader(t *testing.T) {
 	tests := []struct {
 		input []byte
@@ -0,0 +1,147 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dispatched is the part of a dispatched event checked by the conformance tests
+type dispatched struct {
+	id, event, data string
+}
+
+// parseStream returns the events a client dispatches for a stream
+func parseStream(t *testing.T, c *Client, stream string) []dispatched {
+	reader := NewEventStreamReader(strings.NewReader(stream), 1<<16)
+
+	var events []dispatched
+	for {
+		msg, err := reader.ReadEvent()
+		if err == io.EOF {
+			return events
+		}
+		require.Nil(t, err)
+
+		if ev := c.parseEvent(msg); ev != nil {
+			events = append(events, dispatched{id: string(ev.ID), event: string(ev.Event), data: string(ev.Data)})
+		}
+	}
+}
+
+// Examples of the event stream interpretation section of the HTML
+// specification, and edge cases of its parsing algorithm
+func TestEventStreamConformance(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		events []dispatched
+	}{
+		{
+			name:   "multiple data lines",
+			stream: "data: YHOO\ndata: +2\ndata: 10\n\n",
+			events: []dispatched{{data: "YHOO\n+2\n10"}},
+		},
+		{
+			name:   "comments and last event id",
+			stream: ": test stream\n\ndata: first event\nid: 1\n\ndata:second event\nid\n\ndata:  third event\n\n",
+			events: []dispatched{
+				{id: "1", data: "first event"},
+				{id: "", data: "second event"},
+				{id: "", data: " third event"},
+			},
+		},
+		{
+			name:   "empty data and incomplete event",
+			stream: "data\n\ndata\ndata\n\ndata:",
+			events: []dispatched{{data: ""}, {data: "\n"}},
+		},
+		{
+			name:   "single optional space",
+			stream: "data:test\n\ndata: test\n\n",
+			events: []dispatched{{data: "test"}, {data: "test"}},
+		},
+		{
+			name:   "byte order mark",
+			stream: "\xEF\xBB\xBFdata: bom\n\n",
+			events: []dispatched{{data: "bom"}},
+		},
+		{
+			name:   "cr and crlf line endings",
+			stream: "data: a\rdata: b\r\n\r\ndata: c\r\rdata: d\n\n",
+			events: []dispatched{{data: "a\nb"}, {data: "c"}, {data: "d"}},
+		},
+		{
+			name:   "events without id keep the last event id",
+			stream: "id: 1\ndata: a\n\ndata: b\n\nid:\ndata: c\n\n",
+			events: []dispatched{{id: "1", data: "a"}, {id: "1", data: "b"}, {id: "", data: "c"}},
+		},
+		{
+			name:   "id containing nul is ignored",
+			stream: "id: 1\ndata: a\n\nid: 2\x003\ndata: b\n\n",
+			events: []dispatched{{id: "1", data: "a"}, {id: "1", data: "b"}},
+		},
+		{
+			name:   "id without data updates the last event id",
+			stream: "id: 5\n\ndata: a\n\n",
+			events: []dispatched{{id: "5", data: "a"}},
+		},
+		{
+			name:   "event type",
+			stream: "event: add\ndata: a\n\nevent\ndata: b\n\n",
+			events: []dispatched{{event: "add", data: "a"}, {event: "", data: "b"}},
+		},
+		{
+			name:   "events without data are not dispatched",
+			stream: "event: add\n\nretry: 1000\n\n",
+		},
+		{
+			name:   "unknown fields are ignored",
+			stream: "foo: bar\ndata: a\nDATA: b\ndata : c\n\n",
+			events: []dispatched{{data: "a"}},
+		},
+		{
+			name:   "value containing colons",
+			stream: "data: a: b:c\n\n",
+			events: []dispatched{{data: "a: b:c"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewClient("")
+			assert.Equal(t, test.events, parseStream(t, c, test.stream))
+		})
+	}
+}
+
+func TestEventStreamRetry(t *testing.T) {
+	c := NewClient("")
+
+	parseStream(t, c, "retry: 3000\n\n")
+	assert.Equal(t, int64(3000), c.serverRetry)
+
+	// Reconnection times that are not made of digits are ignored
+	parseStream(t, c, "retry: 10s\n\nretry: -1\n\nretry\n\n")
+	assert.Equal(t, int64(3000), c.serverRetry)
+}
+
+func TestEventStreamComments(t *testing.T) {
+	c := NewClient("")
+
+	ev, err := c.processEvent([]byte(": first\ndata: a\n:second\n:"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("a"), ev.Data)
+	assert.Equal(t, []byte("first\nsecond\n"), ev.Comment)
+
+	ev, err = c.processEvent([]byte("data: a"))
+	require.Nil(t, err)
+	assert.Nil(t, ev.Comment)
+}
@@ -0,0 +1,79 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+// eventHandler is a handler registered with the client's dispatcher
+type eventHandler struct {
+	id      int
+	name    string
+	any     bool
+	handler func(msg *Event)
+}
+
+// On registers a handler for the events of the given name. Handlers can be
+// registered and removed at any time, including while subscribed. It returns
+// a function removing the handler.
+func (c *Client) On(eventName string, handler func(msg *Event)) func() {
+	return c.addHandler(eventHandler{name: eventName, handler: handler})
+}
+
+// OnMessage registers a handler for unnamed events, those of the "message"
+// type
+func (c *Client) OnMessage(handler func(msg *Event)) func() {
+	return c.On(defaultEventType, handler)
+}
+
+// OnAny registers a handler for all events
+func (c *Client) OnAny(handler func(msg *Event)) func() {
+	return c.addHandler(eventHandler{any: true, handler: handler})
+}
+
+// Dispatch calls the handlers registered for the event, in registration
+// order. It is meant to be passed to the subscribe methods, e.g.
+// client.Subscribe("messages", client.Dispatch).
+func (c *Client) Dispatch(msg *Event) {
+	name := string(msg.Event)
+	if name == "" {
+		name = defaultEventType
+	}
+
+	c.muHandlers.RLock()
+	handlers := make([]func(msg *Event), 0, len(c.handlers))
+	for _, h := range c.handlers {
+		if h.any || h.name == name {
+			handlers = append(handlers, h.handler)
+		}
+	}
+	c.muHandlers.RUnlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+}
+
+func (c *Client) addHandler(h eventHandler) func() {
+	c.muHandlers.Lock()
+	defer c.muHandlers.Unlock()
+
+	h.id = c.nextHandler
+	c.nextHandler++
+	c.handlers = append(c.handlers, h)
+
+	return func() {
+		c.removeHandler(h.id)
+	}
+}
+
+func (c *Client) removeHandler(id int) {
+	c.muHandlers.Lock()
+	defer c.muHandlers.Unlock()
+
+	for i := range c.handlers {
+		if c.handlers[i].id == id {
+			c.handlers = append(c.handlers[:i:i], c.handlers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientDispatch(t *testing.T) {
+	c := NewClient("")
+
+	var calls []string
+	c.On("created", func(msg *Event) { calls = append(calls, "created "+string(msg.Data)) })
+	removeMessage := c.OnMessage(func(msg *Event) { calls = append(calls, "message "+string(msg.Data)) })
+	c.OnAny(func(msg *Event) { calls = append(calls, "any "+string(msg.Data)) })
+
+	c.Dispatch(&Event{Data: []byte("1"), Event: []byte("created")})
+	c.Dispatch(&Event{Data: []byte("2")})
+	c.Dispatch(&Event{Data: []byte("3"), Event: []byte("deleted")})
+
+	removeMessage()
+	removeMessage()
+	c.Dispatch(&Event{Data: []byte("4")})
+
+	assert.Equal(t, []string{
+		"created 1", "any 1",
+		"message 2", "any 2",
+		"any 3",
+		"any 4",
+	}, calls)
+}
+
+func TestClientDispatchConcurrent(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	c := NewClient(server.URL)
+
+	received := make(chan *Event, 1)
+	c.On("ping", func(msg *Event) {
+		select {
+		case received <- msg:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.SubscribeWithContext(ctx, "test", c.Dispatch)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				remove := c.OnAny(func(msg *Event) {})
+				c.Dispatch(&Event{Data: []byte("local")})
+				remove()
+			}
+		}()
+	}
+
+	// Publish until the client is connected
+	for i := 0; i < 40; i++ {
+		s.Publish("test", &Event{Data: []byte("ping"), Event: []byte("ping")})
+
+		select {
+		case msg := <-received:
+			require.Equal(t, []byte("ping"), msg.Data)
+			wg.Wait()
+			return
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+
+	t.Fatal("event not dispatched")
+}
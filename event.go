@@ -5,9 +5,9 @@
 package sse
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"time"
 )
@@ -20,95 +20,192 @@ type Event struct {
 	Event     []byte
 	Retry     []byte
 	Comment   []byte
+	Stream    []byte
+	// Trace context of the span that published or delivered the event, set
+	// when a Tracer is used
+	TraceContext []byte
 }
 
 func (e *Event) hasContent() bool {
 	return len(e.ID) > 0 || len(e.Data) > 0 || len(e.Event) > 0 || len(e.Retry) > 0
 }
 
+// byteOrderMark is the UTF-8 byte order mark
+var byteOrderMark = []byte("\xEF\xBB\xBF")
+
+// ErrEventTooLarge is returned by ReadEvent when an event exceeds the
+// reader's maximum size. The event is skipped, later events can still be read.
+var ErrEventTooLarge = errors.New("event exceeds the maximum size")
+
+const (
+	// size of the buffer the stream is read into
+	readBufferSize = 4096
+	// capacity above which the event buffer of an unbounded reader is not
+	// kept between events
+	maxRetainedEventSize = 1 << 20
+	// number of consecutive empty reads after which reading fails
+	maxEmptyReads = 100
+)
+
 // EventStreamReader scans an io.Reader looking for EventStream messages.
+// Lines are read incrementally and their line endings normalized to "\n",
+// so each byte of the stream is only looked at once.
 type EventStreamReader struct {
-	scanner *bufio.Scanner
+	reader io.Reader
+	buf    []byte
+	// unread bytes of buf
+	start, end int
+	// error of the last read, returned once buf is consumed
+	err error
+
+	// the current event, reused from one event to the next
+	event   []byte
+	maxSize int
+
+	bomChecked bool
+	// the last line ended with a cr, which a lf may follow
+	skipLF bool
 }
 
-// NewEventStreamReader creates an instance of EventStreamReader.
+// NewEventStreamReader creates an instance of EventStreamReader. Events
+// larger than maxBufferSize are skipped with ErrEventTooLarge, their size is
+// not limited when maxBufferSize is zero or negative.
 func NewEventStreamReader(eventStream io.Reader, maxBufferSize int) *EventStreamReader {
-	scanner := bufio.NewScanner(eventStream)
-	initBufferSize := minPosInt(4096, maxBufferSize)
-	scanner.Buffer(make([]byte, initBufferSize), maxBufferSize)
+	return &EventStreamReader{
+		reader:  eventStream,
+		buf:     make([]byte, readBufferSize),
+		maxSize: maxBufferSize,
+	}
+}
 
-	split := func(data []byte, atEOF bool) (int, []byte, error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
+// ReadEvent scans the EventStream for events. It returns the lines of the
+// next event, separated by "\n", which are only valid until the next call.
+// An incomplete event at the end of the stream is discarded.
+func (e *EventStreamReader) ReadEvent() ([]byte, error) {
+	// The buffer of unbounded readers is not kept after large events
+	if e.maxSize <= 0 && cap(e.event) > maxRetainedEventSize {
+		e.event = nil
+	}
+	e.event = e.event[:0]
+
+	// Start of the current line in event, and whether it is empty
+	lineStart := 0
+	lineEmpty := true
+	tooLarge := false
+
+	for {
+		if e.start == e.end {
+			if err := e.fill(); err != nil {
+				if err == context.Canceled {
+					return nil, io.EOF
+				}
+				return nil, err
+			}
 		}
 
-		// We have a full event payload to parse.
-		if i, nlen := containsDoubleNewline(data); i >= 0 {
-			return i + nlen, data[0:i], nil
+		// Skip the lf of a crlf line ending
+		if e.skipLF {
+			e.skipLF = false
+			if e.buf[e.start] == '\n' {
+				e.start++
+				continue
+			}
 		}
-		// If we're at EOF, we have all of the data.
-		if atEOF {
-			return len(data), data, nil
+
+		// Skip the byte order mark the stream may start with
+		if !e.bomChecked {
+			if e.end-e.start < len(byteOrderMark) && bytes.HasPrefix(byteOrderMark, e.buf[e.start:e.end]) {
+				if err := e.fill(); err == nil {
+					continue
+				}
+			}
+			e.bomChecked = true
+			if bytes.HasPrefix(e.buf[e.start:e.end], byteOrderMark) {
+				e.start += len(byteOrderMark)
+				continue
+			}
 		}
-		// Request more data.
-		return 0, nil, nil
-	}
-	// Set the split function for the scanning operation.
-	scanner.Split(split)
 
-	return &EventStreamReader{
-		scanner: scanner,
-	}
-}
+		chunk := e.buf[e.start:e.end]
+		i := indexLineEnd(chunk)
+		if i < 0 {
+			i = len(chunk)
+		}
+
+		if i > 0 {
+			lineEmpty = false
+			if !tooLarge && e.maxSize > 0 && len(e.event)+i > e.maxSize {
+				tooLarge = true
+			}
+			if !tooLarge {
+				e.event = append(e.event, chunk[:i]...)
+			}
+		}
+
+		if i == len(chunk) {
+			// Request more data
+			e.start = e.end
+			continue
+		}
 
-// Returns a tuple containing the index of a double newline, and the number of bytes
-// represented by that sequence. If no double newline is present, the first value
-// will be negative.
-func containsDoubleNewline(data []byte) (int, int) {
-	// Search for each potentially valid sequence of newline characters
-	crcr := bytes.Index(data, []byte("\r\r"))
-	lflf := bytes.Index(data, []byte("\n\n"))
-	crlflf := bytes.Index(data, []byte("\r\n\n"))
-	lfcrlf := bytes.Index(data, []byte("\n\r\n"))
-	crlfcrlf := bytes.Index(data, []byte("\r\n\r\n"))
-	// Find the earliest position of a double newline combination
-	minPos := minPosInt(crcr, minPosInt(lflf, minPosInt(crlflf, minPosInt(lfcrlf, crlfcrlf))))
-	// Detemine the length of the sequence
-	nlen := 2
-	if minPos == crlfcrlf {
-		nlen = 4
-	} else if minPos == crlflf || minPos == lfcrlf {
-		nlen = 3
+		e.start += i + 1
+		e.skipLF = chunk[i] == '\r'
+
+		// A blank line dispatches the event
+		if lineEmpty {
+			if tooLarge {
+				return nil, ErrEventTooLarge
+			}
+			if lineStart > 0 {
+				return e.event[:lineStart-1], nil
+			}
+			return e.event, nil
+		}
+
+		if !tooLarge {
+			e.event = append(e.event, '\n')
+		}
+		lineStart = len(e.event)
+		lineEmpty = true
 	}
-	return minPos, nlen
 }
 
-// Returns the minimum non-negative value out of the two values. If both
-// are negative, a negative value is returned.
-func minPosInt(a, b int) int {
-	if a < 0 {
-		return b
+// fill reads more of the stream into buf, keeping its unread bytes
+func (e *EventStreamReader) fill() error {
+	if e.start > 0 {
+		e.end = copy(e.buf, e.buf[e.start:e.end])
+		e.start = 0
 	}
-	if b < 0 {
-		return a
+
+	if e.err != nil {
+		return e.err
 	}
-	if a > b {
-		return b
+
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := e.reader.Read(e.buf[e.end:])
+		e.end += n
+		if err != nil {
+			e.err = err
+		}
+		if n > 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 	}
-	return a
+
+	return io.ErrNoProgress
 }
 
-// ReadEvent scans the EventStream for events.
-func (e *EventStreamReader) ReadEvent() ([]byte, error) {
-	if e.scanner.Scan() {
-		event := e.scanner.Bytes()
-		return event, nil
+// indexLineEnd returns the index of the first cr or lf, or -1 if there is none
+func indexLineEnd(data []byte) int {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return bytes.IndexByte(data, '\r')
 	}
-	if err := e.scanner.Err(); err != nil {
-		if err == context.Canceled {
-			return nil, io.EOF
-		}
-		return nil, err
+	if j := bytes.IndexByte(data[:i], '\r'); j >= 0 {
+		return j
 	}
-	return nil, io.EOF
+	return i
 }
@@ -6,38 +6,200 @@ package sse
 
 import (
 	"strconv"
+	"sync"
 	"time"
 )
 
-// EventLog holds all of previous events
-type EventLog []*Event
+// EventLogLimits bounds the size of an event log. Once a limit is reached the
+// oldest events are evicted. A zero value disables the limit.
+type EventLogLimits struct {
+	// Maximum number of events held by the log
+	MaxEvents int
+	// Maximum total size in bytes of the events held by the log
+	MaxBytes int
+	// Maximum age of the events held by the log
+	MaxAge time.Duration
+}
+
+// EventLog is the default in-memory EventStore. It holds all of previous
+// events, unless bounded by limits.
+type EventLog struct {
+	events []*Event
+	nextID int
+	size   int
+	limits EventLogLimits
+	mu     sync.RWMutex
+	// called with the number of events evicted to stay within the limits
+	onEvict func(n int)
+}
+
+// NewEventLog creates an empty in-memory event log
+func NewEventLog() *EventLog {
+	return &EventLog{}
+}
+
+// NewBoundedEventLog creates an empty in-memory event log bounded by limits
+func NewBoundedEventLog(limits EventLogLimits) *EventLog {
+	return &EventLog{limits: limits}
+}
+
+// SetLimits changes the limits of the eventlog, evicting events if needed
+func (e *EventLog) SetLimits(limits EventLogLimits) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.limits = limits
+	e.evict()
+}
 
 // Add event to eventlog
 func (e *EventLog) Add(ev *Event) {
+	_ = e.Append(ev)
+}
+
+// Append event to eventlog, assigning it the next event id if it has none
+func (e *EventLog) Append(ev *Event) error {
 	if !ev.hasContent() {
-		return
+		return nil
 	}
 
-	ev.ID = []byte(e.currentindex())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(ev.ID) == 0 {
+		ev.ID = []byte(strconv.Itoa(e.nextID))
+		e.nextID++
+	}
 	ev.timestamp = time.Now()
-	*e = append(*e, ev)
+	e.events = append(e.events, ev)
+	e.size += eventSize(ev)
+	e.evict()
+
+	return nil
+}
+
+// ReplayFrom returns the event with the given id and all events logged after
+// it. If the id is not found, all events are returned.
+func (e *EventLog) ReplayFrom(id string) ([]*Event, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.evict()
+
+	i := indexOf(e.events, id)
+	if i < 0 {
+		i = 0
+	}
+
+	return append([]*Event(nil), e.events[i:]...), nil
+}
+
+// Replay sends the logged events the subscriber has not seen yet
+//
+// Deprecated: use ReplayFrom
+func (e *EventLog) Replay(s *Subscriber) {
+	events, _ := e.ReplayFrom(s.eventid)
+	for i := range events {
+		s.connection <- events[i]
+	}
+}
+
+// Trim removes all events logged before the event with the given id. Ids of
+// new events keep increasing after a trim.
+func (e *EventLog) Trim(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if i := indexOf(e.events, id); i > 0 {
+		e.remove(i)
+	}
+
+	return nil
 }
 
 // Clear events from eventlog
 func (e *EventLog) Clear() {
-	*e = nil
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = nil
+	e.size = 0
 }
 
-// Replay events to a subscriber
-func (e *EventLog) Replay(s *Subscriber) {
-	for i := 0; i < len(*e); i++ {
-		id, _ := strconv.Atoi(string((*e)[i].ID))
-		if id >= s.eventid {
-			s.connection <- (*e)[i]
+// Len returns the number of events held by the eventlog
+func (e *EventLog) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return len(e.events)
+}
+
+// Close satisfies the EventStore interface, there is nothing to release
+func (e *EventLog) Close() error {
+	return nil
+}
+
+// evict removes the oldest events until the log is within its limits
+func (e *EventLog) evict() {
+	n := 0
+
+	if e.limits.MaxAge > 0 {
+		deadline := time.Now().Add(-e.limits.MaxAge)
+		for n < len(e.events) && e.events[n].timestamp.Before(deadline) {
+			n++
 		}
 	}
+
+	if e.limits.MaxEvents > 0 && len(e.events)-n > e.limits.MaxEvents {
+		n = len(e.events) - e.limits.MaxEvents
+	}
+
+	if e.limits.MaxBytes > 0 {
+		size := e.size
+		for i := 0; i < n; i++ {
+			size -= eventSize(e.events[i])
+		}
+		for n < len(e.events) && size > e.limits.MaxBytes {
+			size -= eventSize(e.events[n])
+			n++
+		}
+	}
+
+	e.remove(n)
+
+	if n > 0 && e.onEvict != nil {
+		e.onEvict(n)
+	}
+}
+
+// remove drops the first n events of the log
+func (e *EventLog) remove(n int) {
+	if n == 0 {
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		e.size -= eventSize(e.events[i])
+		e.events[i] = nil
+	}
+	e.events = e.events[n:]
 }
 
-func (e *EventLog) currentindex() string {
-	return strconv.Itoa(len(*e))
+func eventSize(ev *Event) int {
+	return len(ev.ID) + len(ev.Data) + len(ev.Event) + len(ev.Retry) + len(ev.Comment)
+}
+
+// indexOf returns the index of the event with the given id, or -1
+func indexOf(events []*Event, id string) int {
+	if id == "" {
+		return -1
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if string(events[i].ID) == id {
+			return i
+		}
+	}
+
+	return -1
 }
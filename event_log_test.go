@@ -6,21 +6,127 @@ package sse
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEventLog(t *testing.T) {
-	ev := make(EventLog, 0)
+	ev := NewEventLog()
 	testEvent := &Event{Data: []byte("test")}
 
 	ev.Add(testEvent)
 	ev.Clear()
 
-	assert.Equal(t, 0, len(ev))
+	assert.Equal(t, 0, ev.Len())
 
 	ev.Add(testEvent)
 	ev.Add(testEvent)
 
-	assert.Equal(t, 2, len(ev))
+	assert.Equal(t, 2, ev.Len())
+}
+
+func TestEventLogReplayFrom(t *testing.T) {
+	ev := NewEventLog()
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	}
+
+	events, err := ev.ReplayFrom("3")
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []byte("3"), events[0].ID)
+	assert.Equal(t, []byte("4"), events[1].ID)
+}
+
+func TestEventLogTrim(t *testing.T) {
+	ev := NewEventLog()
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	}
+
+	require.Nil(t, ev.Trim("3"))
+	assert.Equal(t, 2, ev.Len())
+
+	testEvent := &Event{Data: []byte("test")}
+	require.Nil(t, ev.Append(testEvent))
+	assert.Equal(t, []byte("5"), testEvent.ID)
+}
+
+func TestEventLogMaxEvents(t *testing.T) {
+	ev := NewBoundedEventLog(EventLogLimits{MaxEvents: 3})
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	}
+
+	assert.Equal(t, 3, ev.Len())
+
+	events, err := ev.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, []byte("2"), events[0].ID)
+	assert.Equal(t, []byte("4"), events[2].ID)
+}
+
+func TestEventLogMaxBytes(t *testing.T) {
+	ev := NewBoundedEventLog(EventLogLimits{MaxBytes: 10})
+
+	require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	assert.Equal(t, 2, ev.Len())
+
+	require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	assert.Equal(t, 2, ev.Len())
+
+	events, err := ev.ReplayFrom("")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("1"), events[0].ID)
+}
+
+func TestEventLogMaxAge(t *testing.T) {
+	ev := NewBoundedEventLog(EventLogLimits{MaxAge: time.Millisecond * 100})
+
+	require.Nil(t, ev.Append(&Event{Data: []byte("test 1")}))
+	time.Sleep(time.Millisecond * 150)
+	require.Nil(t, ev.Append(&Event{Data: []byte("test 2")}))
+
+	events, err := ev.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, []byte("test 2"), events[0].Data)
+}
+
+func TestEventLogSetLimits(t *testing.T) {
+	ev := NewEventLog()
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, ev.Append(&Event{Data: []byte("test")}))
+	}
+
+	ev.SetLimits(EventLogLimits{MaxEvents: 1})
+	assert.Equal(t, 1, ev.Len())
+}
+
+func TestEventLogCallerSuppliedIDs(t *testing.T) {
+	ev := NewEventLog()
+
+	require.Nil(t, ev.Append(&Event{ID: []byte("a"), Data: []byte("test 1")}))
+	require.Nil(t, ev.Append(&Event{ID: []byte("b"), Data: []byte("test 2")}))
+	require.Nil(t, ev.Append(&Event{ID: []byte("c"), Data: []byte("test 3")}))
+
+	events, err := ev.ReplayFrom("b")
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []byte("test 2"), events[0].Data)
+
+	events, err = ev.ReplayFrom("unknown")
+	require.Nil(t, err)
+	assert.Len(t, events, 3)
+
+	require.Nil(t, ev.Trim("c"))
+	assert.Equal(t, 1, ev.Len())
 }
@@ -0,0 +1,300 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventStore is the storage backend of a stream's event log. Stores hand
+// events back for replay to new subscribers and assign sequential ids to
+// events that were published without one.
+type EventStore interface {
+	// Append stores an event, assigning it the next sequential id if it has none
+	Append(ev *Event) error
+	// ReplayFrom returns the event with the given id and all events stored
+	// after it. If the id is not found, all stored events are returned.
+	ReplayFrom(id string) ([]*Event, error)
+	// Trim removes all events stored before the event with the given id
+	Trim(id string) error
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// storedEvent is the on-disk representation of an event
+type storedEvent struct {
+	ID        []byte    `json:"id"`
+	Data      []byte    `json:"data,omitempty"`
+	Event     []byte    `json:"event,omitempty"`
+	Retry     []byte    `json:"retry,omitempty"`
+	Comment   []byte    `json:"comment,omitempty"`
+	Trace     []byte    `json:"trace,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// FileEventStore is an append-only EventStore backed by a file, one JSON
+// encoded event per line. Events survive restarts of the process. The
+// offset of each event in the file is kept in memory, so replays only read
+// the events they return.
+type FileEventStore struct {
+	// Maximum number of events kept in the file, unbounded when zero. The
+	// oldest events are trimmed once the file holds twice as many.
+	MaxEvents int
+
+	path    string
+	file    *os.File
+	nextID  int
+	entries []fileEntry
+	size    int64
+	mu      sync.Mutex
+}
+
+// fileEntry locates an event in the file
+type fileEntry struct {
+	id     string
+	offset int64
+}
+
+// NewFileEventStore opens or creates the event log file at path
+func NewFileEventStore(path string) (*FileEventStore, error) {
+	fs := &FileEventStore{path: path}
+
+	if err := fs.index(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fs.file = file
+
+	return fs, nil
+}
+
+// Append event to the file, assigning it the next event id if it has none
+func (f *FileEventStore) Append(ev *Event) error {
+	if !ev.hasContent() {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	assigned := len(ev.ID) == 0
+	if assigned {
+		ev.ID = []byte(strconv.Itoa(f.nextID))
+	}
+	ev.timestamp = time.Now()
+
+	data, err := json.Marshal(toStoredEvent(ev))
+	if err != nil {
+		return err
+	}
+
+	offset := f.size
+	n, err := f.file.Write(append(data, '\n'))
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	f.entries = append(f.entries, fileEntry{id: string(ev.ID), offset: offset})
+	if assigned {
+		f.nextID++
+	}
+
+	if f.MaxEvents > 0 && len(f.entries) >= 2*f.MaxEvents {
+		return f.trim(len(f.entries) - f.MaxEvents)
+	}
+
+	return nil
+}
+
+// ReplayFrom reads the event with the given id and all events stored after it
+func (f *FileEventStore) ReplayFrom(id string) ([]*Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var offset int64
+	if i := f.indexOf(id); i > 0 {
+		offset = f.entries[i].offset
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, len(f.entries))
+
+	dec := json.NewDecoder(bufio.NewReader(io.LimitReader(file, f.size-offset)))
+	for dec.More() {
+		var se storedEvent
+		if err := dec.Decode(&se); err != nil {
+			return nil, err
+		}
+		events = append(events, se.toEvent())
+	}
+
+	return events, nil
+}
+
+// Trim rewrites the file without the events stored before the event with the
+// given id
+func (f *FileEventStore) Trim(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.indexOf(id)
+	if i <= 0 {
+		return nil
+	}
+
+	return f.trim(i)
+}
+
+// Close closes the underlying file
+func (f *FileEventStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+// trim rewrites the file from its i-th event
+func (f *FileEventStore) trim(i int) error {
+	offset := f.entries[i].offset
+
+	src, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if _, err = src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp, err := os.Create(f.path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(tmp, io.LimitReader(src, f.size-offset)); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = f.file.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(f.path+".tmp", f.path); err != nil {
+		return err
+	}
+
+	f.file, err = os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	f.entries = append([]fileEntry(nil), f.entries[i:]...)
+	for j := range f.entries {
+		f.entries[j].offset -= offset
+	}
+	f.size -= offset
+
+	return nil
+}
+
+// index reads the ids and offsets of the events stored in the file
+func (f *FileEventStore) index() error {
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var se storedEvent
+		if err := json.Unmarshal(line, &se); err != nil {
+			return err
+		}
+
+		f.entries = append(f.entries, fileEntry{id: string(se.ID), offset: f.size})
+		f.size += int64(len(line))
+
+		if id, err := strconv.Atoi(string(se.ID)); err == nil && id >= f.nextID {
+			f.nextID = id + 1
+		}
+	}
+}
+
+// indexOf returns the index of the entry with the given id, or -1
+func (f *FileEventStore) indexOf(id string) int {
+	if id == "" {
+		return -1
+	}
+
+	for i := len(f.entries) - 1; i >= 0; i-- {
+		if f.entries[i].id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func toStoredEvent(ev *Event) *storedEvent {
+	return &storedEvent{
+		ID:        ev.ID,
+		Data:      ev.Data,
+		Event:     ev.Event,
+		Retry:     ev.Retry,
+		Comment:   ev.Comment,
+		Trace:     ev.TraceContext,
+		Timestamp: ev.timestamp,
+	}
+}
+
+func (se *storedEvent) toEvent() *Event {
+	return &Event{
+		timestamp:    se.Timestamp,
+		ID:           se.ID,
+		Data:         se.Data,
+		Event:        se.Event,
+		Retry:        se.Retry,
+		Comment:      se.Comment,
+		TraceContext: se.Trace,
+	}
+}
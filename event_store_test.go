@@ -0,0 +1,184 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempStorePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "sse")
+	require.Nil(t, err)
+	return filepath.Join(dir, "test.log"), func() { os.RemoveAll(dir) }
+}
+
+func TestFileEventStore(t *testing.T) {
+	path, clean := tempStorePath(t)
+	defer clean()
+
+	fs, err := NewFileEventStore(path)
+	require.Nil(t, err)
+
+	require.Nil(t, fs.Append(&Event{Data: []byte("test 1")}))
+	require.Nil(t, fs.Append(&Event{Data: []byte("test 2"), Event: []byte("update")}))
+	require.Nil(t, fs.Append(&Event{}))
+
+	events, err := fs.ReplayFrom("1")
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, []byte("1"), events[0].ID)
+	assert.Equal(t, []byte("test 2"), events[0].Data)
+	assert.Equal(t, []byte("update"), events[0].Event)
+	assert.False(t, events[0].timestamp.IsZero())
+}
+
+func TestFileEventStoreRestart(t *testing.T) {
+	path, clean := tempStorePath(t)
+	defer clean()
+
+	fs, err := NewFileEventStore(path)
+	require.Nil(t, err)
+	require.Nil(t, fs.Append(&Event{Data: []byte("test 1")}))
+	require.Nil(t, fs.Append(&Event{Data: []byte("test 2")}))
+	require.Nil(t, fs.Close())
+
+	fs, err = NewFileEventStore(path)
+	require.Nil(t, err)
+	defer fs.Close()
+
+	ev := &Event{Data: []byte("test 3")}
+	require.Nil(t, fs.Append(ev))
+	assert.Equal(t, []byte("2"), ev.ID)
+
+	events, err := fs.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, []byte("test 1"), events[0].Data)
+	assert.Equal(t, []byte("test 3"), events[2].Data)
+}
+
+func TestFileEventStoreTrim(t *testing.T) {
+	path, clean := tempStorePath(t)
+	defer clean()
+
+	fs, err := NewFileEventStore(path)
+	require.Nil(t, err)
+	defer fs.Close()
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, fs.Append(&Event{Data: []byte("test")}))
+	}
+
+	require.Nil(t, fs.Trim("3"))
+
+	events, err := fs.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []byte("3"), events[0].ID)
+
+	ev := &Event{Data: []byte("test")}
+	require.Nil(t, fs.Append(ev))
+	assert.Equal(t, []byte("5"), ev.ID)
+}
+
+func TestFileEventStoreMaxEvents(t *testing.T) {
+	path, clean := tempStorePath(t)
+	defer clean()
+
+	fs, err := NewFileEventStore(path)
+	require.Nil(t, err)
+	fs.MaxEvents = 2
+
+	for i := 0; i < 5; i++ {
+		require.Nil(t, fs.Append(&Event{Data: []byte("test")}))
+	}
+	require.Nil(t, fs.Close())
+
+	// The file was trimmed to the last 2 events when it reached 4
+	fs, err = NewFileEventStore(path)
+	require.Nil(t, err)
+	defer fs.Close()
+
+	events, err := fs.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, []byte("2"), events[0].ID)
+
+	events, err = fs.ReplayFrom("3")
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []byte("3"), events[0].ID)
+	assert.Equal(t, []byte("4"), events[1].ID)
+}
+
+// failingStore is an event store whose operations fail
+type failingStore struct{}
+
+var errStoreFailed = errors.New("store failed")
+
+func (failingStore) Append(ev *Event) error                 { return errStoreFailed }
+func (failingStore) ReplayFrom(id string) ([]*Event, error) { return nil, errStoreFailed }
+func (failingStore) Trim(id string) error                   { return errStoreFailed }
+func (failingStore) Close() error                           { return nil }
+
+func TestServerEventStoreError(t *testing.T) {
+	errs := make(chan error, 2)
+
+	s := New()
+	s.EventStoreFactory = func(streamID string) EventStore {
+		return failingStore{}
+	}
+	s.OnError = func(streamID string, err error) {
+		errs <- err
+	}
+	defer s.Close()
+
+	stream := s.CreateStream("test")
+	stream.addSubscriber("", nil)
+	s.Publish("test", &Event{Data: []byte("test")})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			assert.Equal(t, errStoreFailed, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected the store errors to be reported")
+		}
+	}
+}
+
+func TestServerEventStoreFactory(t *testing.T) {
+	path, clean := tempStorePath(t)
+	defer clean()
+
+	s := New()
+	s.EventStoreFactory = func(streamID string) EventStore {
+		fs, err := NewFileEventStore(path)
+		require.Nil(t, err)
+		return fs
+	}
+
+	s.CreateStream("test")
+	s.Publish("test", &Event{Data: []byte("test")})
+	time.Sleep(time.Millisecond * 100)
+	s.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	s.CreateStream("test")
+	defer s.Close()
+
+	sub := s.getStream("test").addSubscriber("", nil)
+	msg, err := wait(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), msg)
+}
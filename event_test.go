@@ -0,0 +1,186 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readEvents(t *testing.T, reader *EventStreamReader) ([]string, error) {
+	var events []string
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			return events, err
+		}
+		events = append(events, string(event))
+	}
+}
+
+func TestEventStreamReaderLineEndings(t *testing.T) {
+	stream := "\xEF\xBB\xBFdata: a\r\ndata: b\r\n\r\ndata: c\r\rid: 1\nevent: d\n\ndata: e"
+
+	expected := []string{"data: a\ndata: b", "data: c", "id: 1\nevent: d"}
+
+	// The same events are read whatever the size of the reads
+	for _, r := range []io.Reader{
+		strings.NewReader(stream),
+		iotest.OneByteReader(strings.NewReader(stream)),
+		iotest.HalfReader(strings.NewReader(stream)),
+		iotest.DataErrReader(strings.NewReader(stream)),
+	} {
+		events, err := readEvents(t, NewEventStreamReader(r, 1<<16))
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, expected, events)
+	}
+}
+
+func TestEventStreamReaderTooLarge(t *testing.T) {
+	large := strings.Repeat("x", 100)
+	stream := "data: a\n\ndata: " + large + "\ndata: b\n\ndata: c\n\n"
+
+	reader := NewEventStreamReader(strings.NewReader(stream), 64)
+
+	event, err := reader.ReadEvent()
+	require.Nil(t, err)
+	assert.Equal(t, "data: a", string(event))
+
+	// The large event is skipped
+	_, err = reader.ReadEvent()
+	assert.Equal(t, ErrEventTooLarge, err)
+
+	event, err = reader.ReadEvent()
+	require.Nil(t, err)
+	assert.Equal(t, "data: c", string(event))
+}
+
+func TestEventStreamReaderUnbounded(t *testing.T) {
+	large := strings.Repeat("x", 1<<20)
+
+	reader := NewEventStreamReader(strings.NewReader("data: "+large+"\n\n"), 0)
+
+	event, err := reader.ReadEvent()
+	require.Nil(t, err)
+	assert.Equal(t, "data: "+large, string(event))
+}
+
+func TestEventStreamReaderError(t *testing.T) {
+	reader := NewEventStreamReader(iotest.TimeoutReader(strings.NewReader("data: a\n\ndata: b")), 1<<16)
+
+	events, err := readEvents(t, reader)
+	assert.Equal(t, iotest.ErrTimeout, err)
+	assert.Equal(t, []string{"data: a"}, events)
+}
+
+// scannerEventReader is the bufio.Scanner based reader EventStreamReader
+// replaced, kept to compare their throughput
+type scannerEventReader struct {
+	scanner *bufio.Scanner
+}
+
+func newScannerEventReader(eventStream io.Reader, maxBufferSize int) *scannerEventReader {
+	scanner := bufio.NewScanner(eventStream)
+	scanner.Buffer(make([]byte, 4096), maxBufferSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i, nlen := scannerDoubleNewline(data); i >= 0 {
+			return i + nlen, data[0:i], nil
+		}
+		if atEOF {
+			return len(data), nil, nil
+		}
+		return 0, nil, nil
+	})
+	return &scannerEventReader{scanner: scanner}
+}
+
+func scannerDoubleNewline(data []byte) (int, int) {
+	pos, nlen := -1, 0
+	for _, sep := range []string{"\r\r", "\n\n", "\r\n\n", "\n\r\n", "\r\n\r\n"} {
+		if i := bytes.Index(data, []byte(sep)); i >= 0 && (pos < 0 || i < pos || i == pos && len(sep) > nlen) {
+			pos, nlen = i, len(sep)
+		}
+	}
+	return pos, nlen
+}
+
+func (e *scannerEventReader) ReadEvent() ([]byte, error) {
+	if e.scanner.Scan() {
+		return e.scanner.Bytes(), nil
+	}
+	if err := e.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func benchmarkStream(count, size int) []byte {
+	var buf bytes.Buffer
+	data := strings.Repeat("x", size)
+	for i := 0; i < count; i++ {
+		buf.WriteString("id: 1\nevent: update\ndata: " + data + "\n\n")
+	}
+	return buf.Bytes()
+}
+
+func benchmarkReader(b *testing.B, stream []byte, read func(r io.Reader) error) {
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := read(bytes.NewReader(stream)); err != io.EOF {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkEventStreamReader(b *testing.B, stream []byte) {
+	benchmarkReader(b, stream, func(r io.Reader) error {
+		reader := NewEventStreamReader(r, 1<<21)
+		for {
+			if _, err := reader.ReadEvent(); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func benchmarkScannerEventReader(b *testing.B, stream []byte) {
+	benchmarkReader(b, stream, func(r io.Reader) error {
+		reader := newScannerEventReader(r, 1<<21)
+		for {
+			if _, err := reader.ReadEvent(); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func BenchmarkEventStreamReaderSmall(b *testing.B) {
+	benchmarkEventStreamReader(b, benchmarkStream(1000, 64))
+}
+
+func BenchmarkScannerEventReaderSmall(b *testing.B) {
+	benchmarkScannerEventReader(b, benchmarkStream(1000, 64))
+}
+
+func BenchmarkEventStreamReaderLarge(b *testing.B) {
+	benchmarkEventStreamReader(b, benchmarkStream(10, 1<<20))
+}
+
+func BenchmarkScannerEventReaderLarge(b *testing.B) {
+	benchmarkScannerEventReader(b, benchmarkStream(10, 1<<20))
+}
@@ -0,0 +1,60 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultEventTypeParam is the query parameter listing the event types a
+// subscriber wants to receive, e.g. "?stream=orders&event=created,deleted"
+const DefaultEventTypeParam = "event"
+
+// defaultEventType is the type of events without an event field
+const defaultEventType = "message"
+
+// FilterFunc decides whether an event is sent to a subscriber, e.g. from the
+// subscriber's URL or Info
+type FilterFunc func(sub *Subscriber, ev *Event) bool
+
+// eventTypes returns the event types listed in the query of a subscriber's
+// url. Both repeated parameters and comma separated lists are accepted.
+func eventTypes(u *url.URL) []string {
+	if u == nil {
+		return nil
+	}
+
+	var types []string
+	for _, value := range u.Query()[DefaultEventTypeParam] {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" && !containsString(types, t) {
+				types = append(types, t)
+			}
+		}
+	}
+
+	return types
+}
+
+// accepts reports whether an event passes the subscriber's filters. Events
+// without data, like comments and retry updates, are always sent.
+func (str *Stream) accepts(sub *Subscriber, ev *Event) bool {
+	if len(ev.Data) == 0 {
+		return true
+	}
+
+	if len(sub.EventTypes) > 0 {
+		t := string(ev.Event)
+		if t == "" {
+			t = defaultEventType
+		}
+		if !containsString(sub.EventTypes, t) {
+			return false
+		}
+	}
+
+	return str.Filter == nil || str.Filter(sub, ev)
+}
@@ -0,0 +1,82 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTypes(t *testing.T) {
+	u, _ := url.Parse("/events?stream=test&event=created,deleted&event=updated&event=created")
+	assert.Equal(t, []string{"created", "deleted", "updated"}, eventTypes(u))
+
+	u, _ = url.Parse("/events?stream=test")
+	assert.Nil(t, eventTypes(u))
+	assert.Nil(t, eventTypes(nil))
+}
+
+func TestStreamEventTypeFilter(t *testing.T) {
+	s := newStream("test", 1024, true, false, nil, nil)
+	s.run()
+	defer s.close()
+
+	s.event <- &Event{Data: []byte("replayed created"), Event: []byte("created")}
+	s.event <- &Event{Data: []byte("replayed updated"), Event: []byte("updated")}
+
+	u, _ := url.Parse("/events?stream=test&event=created,message")
+	sub := s.addSubscriber("", u)
+
+	s.event <- &Event{Data: []byte("updated"), Event: []byte("updated")}
+	s.event <- &Event{Data: []byte("unnamed")}
+	s.event <- &Event{Comment: []byte("comment")}
+
+	msg, err := wait(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("replayed created"), msg)
+
+	msg, err = wait(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("unnamed"), msg)
+
+	ev, err := waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("comment"), ev.Comment)
+	assert.Equal(t, 0, len(sub.connection))
+}
+
+func TestServerFilter(t *testing.T) {
+	s := New()
+	s.Filter = func(sub *Subscriber, ev *Event) bool {
+		return sub.URL.Query().Get("tenant") == string(ev.Event)
+	}
+	defer s.Close()
+
+	s.CreateStream("test")
+	stream := s.getStream("test")
+
+	a, _ := url.Parse("/events?stream=test&tenant=a")
+	b, _ := url.Parse("/events?stream=test&tenant=b")
+	subA := stream.addSubscriber("", a)
+	subB := stream.addSubscriber("", b)
+
+	s.Publish("test", &Event{Data: []byte("for a"), Event: []byte("a")})
+	s.Publish("test", &Event{Data: []byte("for b"), Event: []byte("b")})
+
+	msg, err := wait(subA.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("for a"), msg)
+
+	msg, err = wait(subB.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("for b"), msg)
+
+	assert.Equal(t, 0, len(subA.connection))
+	assert.Equal(t, 0, len(subB.connection))
+}
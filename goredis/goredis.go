@@ -0,0 +1,111 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+// Package goredis implements sse.RedisClient on top of go-redis. It is a
+// separate module so that the sse package does not depend on a redis library.
+package goredis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/r3labs/sse/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// messageField is the field of the redis stream entries holding the event
+const messageField = "message"
+
+// Client adapts a go-redis client to sse.RedisClient
+type Client struct {
+	rdb redis.UniversalClient
+}
+
+var _ sse.RedisClient = (*Client)(nil)
+
+// New creates an adapter for the given go-redis client
+func New(rdb redis.UniversalClient) *Client {
+	return &Client{rdb: rdb}
+}
+
+// NewBroker creates a redis broker using the given go-redis client
+func NewBroker(rdb redis.UniversalClient) *sse.RedisBroker {
+	return sse.NewRedisBroker(New(rdb))
+}
+
+// Publish posts a message on a pub/sub channel
+func (c *Client) Publish(ctx context.Context, channel string, message []byte) error {
+	return c.rdb.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe calls handler with every message posted on a pub/sub channel
+// until ctx is canceled. It returns once the subscription is confirmed.
+func (c *Client) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	pubsub := c.rdb.Subscribe(ctx, channel)
+
+	// wait for the confirmation, so no message published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	ch := pubsub.Channel()
+
+	go func() {
+		defer pubsub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				handler([]byte(msg.Payload))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// XAdd appends a message to a stream, trimming it to approximately maxLen
+// entries when maxLen is positive, and returns the id of the new entry
+func (c *Client) XAdd(ctx context.Context, stream string, maxLen int64, message []byte) (string, error) {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: []interface{}{messageField, message},
+	}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+
+	return c.rdb.XAdd(ctx, args).Result()
+}
+
+// XRange returns the entries of a stream with an id greater or equal to start
+func (c *Client) XRange(ctx context.Context, stream, start string) ([]sse.RedisStreamEntry, error) {
+	msgs, err := c.rdb.XRange(ctx, stream, start, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]sse.RedisStreamEntry, 0, len(msgs))
+	for _, msg := range msgs {
+		message, ok := msg.Values[messageField].(string)
+		if !ok {
+			return nil, fmt.Errorf("goredis: entry %s of %s has no %s field", msg.ID, stream, messageField)
+		}
+		entries = append(entries, sse.RedisStreamEntry{ID: msg.ID, Message: []byte(message)})
+	}
+
+	return entries, nil
+}
+
+// XTrim removes the entries of a stream with an id lower than minID
+func (c *Client) XTrim(ctx context.Context, stream, minID string) error {
+	return c.rdb.XTrimMinID(ctx, stream, minID).Err()
+}
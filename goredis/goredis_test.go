@@ -0,0 +1,146 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package goredis
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/r3labs/sse/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	mr, err := miniredis.Run()
+	require.Nil(t, err)
+
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestClientStream(t *testing.T) {
+	mr, rdb := newRedis(t)
+	defer mr.Close()
+	defer rdb.Close()
+
+	c := New(rdb)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		id, err := c.XAdd(ctx, "log", 0, []byte("message "+strconv.Itoa(i)))
+		require.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	entries, err := c.XRange(ctx, "log", ids[1])
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, ids[1], entries[0].ID)
+	assert.Equal(t, []byte("message 2"), entries[0].Message)
+
+	require.Nil(t, c.XTrim(ctx, "log", ids[2]))
+
+	entries, err = c.XRange(ctx, "log", "-")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []byte("message 3"), entries[0].Message)
+}
+
+func TestClientMaxLen(t *testing.T) {
+	mr, rdb := newRedis(t)
+	defer mr.Close()
+	defer rdb.Close()
+
+	c := New(rdb)
+	ctx := context.Background()
+
+	for i := 1; i <= 10; i++ {
+		_, err := c.XAdd(ctx, "log", 5, []byte("message "+strconv.Itoa(i)))
+		require.Nil(t, err)
+	}
+
+	entries, err := c.XRange(ctx, "log", "-")
+	require.Nil(t, err)
+	assert.True(t, len(entries) >= 5)
+	assert.Equal(t, []byte("message 10"), entries[len(entries)-1].Message)
+}
+
+func TestClientPubSub(t *testing.T) {
+	mr, rdb := newRedis(t)
+	defer mr.Close()
+	defer rdb.Close()
+
+	c := New(rdb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan []byte, 1)
+	require.Nil(t, c.Subscribe(ctx, "events", func(message []byte) {
+		messages <- message
+	}))
+
+	require.Nil(t, c.Publish(context.Background(), "events", []byte("test")))
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, []byte("test"), message)
+	case <-time.After(time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	mr, rdb := newRedis(t)
+	defer mr.Close()
+	defer rdb.Close()
+
+	s1 := sse.New()
+	s1.Broker = NewBroker(rdb)
+	defer s1.Close()
+
+	s2 := sse.New()
+	s2.Broker = NewBroker(rdb)
+	defer s2.Close()
+
+	s1.CreateStream("test")
+	s2.CreateStream("test")
+
+	server := httptest.NewServer(s2)
+	defer server.Close()
+
+	events := make(chan *sse.Event)
+	client := sse.NewClient(server.URL)
+	require.Nil(t, client.SubscribeChan("test", events))
+	defer client.Unsubscribe(events)
+
+	// the subscription of the second instance is asynchronous
+	time.Sleep(time.Millisecond * 100)
+
+	for i := 1; i <= 2; i++ {
+		s1.Publish("test", &sse.Event{Data: []byte("test " + strconv.Itoa(i))})
+	}
+
+	var first *sse.Event
+	select {
+	case first = <-events:
+		assert.Equal(t, []byte("test 1"), first.Data)
+		assert.NotEmpty(t, first.ID)
+	case <-time.After(time.Second):
+		t.Fatal("event was not fanned out")
+	}
+
+	// a third instance replays the shared log
+	replayed, err := NewBroker(rdb).EventStore("test").ReplayFrom(string(first.ID))
+	require.Nil(t, err)
+	require.Len(t, replayed, 2)
+	assert.Equal(t, []byte("test 2"), replayed[1].Data)
+}
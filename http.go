@@ -7,19 +7,40 @@ package sse
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"net/http"
-	"strconv"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 )
 
+// streamEvent is an event tagged with the stream it was published to
+type streamEvent struct {
+	stream  string
+	event   *Event
+	metrics Metrics
+}
+
 // ServeHTTP serves new connections with events for a given stream ...
+//
+// Several streams can be subscribed to over a single connection by repeating
+// the stream query parameter. Each frame then carries a stream: field naming
+// its source stream, and its id is a resume token holding the last event id
+// of every stream.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	flusher, err := w.(http.Flusher)
-	if !err {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
 		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
 		return
 	}
 
+	if !s.startHandler() {
+		http.Error(w, "Server is shutting down!", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.handlers.Done()
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -28,93 +49,349 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set(k, v)
 	}
 
-	// Get the StreamID from the URL
-	streamID := r.URL.Query().Get("stream")
-	if streamID == "" {
+	// Get the StreamIDs from the request
+	streamIDs, err := s.resolveStreamIDs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(streamIDs) == 0 {
 		http.Error(w, "Please specify a stream!", http.StatusInternalServerError)
 		return
 	}
 
-	stream := s.getStream(streamID)
-
-	if stream == nil {
-		if !s.AutoStream {
-			http.Error(w, "Stream not found!", http.StatusInternalServerError)
+	infos := make([]SubscriberInfo, len(streamIDs))
+	for i, streamID := range streamIDs {
+		info, status, err := s.authorize(r, streamID)
+		if err != nil {
+			http.Error(w, err.Error(), status)
 			return
 		}
+		infos[i] = info
+	}
+
+	var streams []*Stream
+	var streamInfos []SubscriberInfo
+	var patterns []string
+	for i, streamID := range streamIDs {
+		if isStreamPattern(streamID) {
+			if !validStreamPattern(streamID) {
+				http.Error(w, "Invalid stream pattern!", http.StatusBadRequest)
+				return
+			}
+			patterns = append(patterns, streamID)
+			continue
+		}
 
-		stream = s.CreateStream(streamID)
+		stream := s.getStream(streamID)
+
+		if stream == nil {
+			if !s.AutoStream {
+				http.Error(w, "Stream not found!", http.StatusInternalServerError)
+				return
+			}
+
+			stream = s.CreateStream(streamID)
+		}
+
+		streams = append(streams, stream)
+		streamInfos = append(streamInfos, infos[i])
 	}
 
-	eventid := 0
-	if id := r.Header.Get("Last-Event-ID"); id != "" {
-		var err error
-		eventid, err = strconv.Atoi(id)
-		if err != nil {
-			http.Error(w, "Last-Event-ID must be a number!", http.StatusBadRequest)
-			return
+	multi := len(streamIDs) > 1 || len(patterns) > 0
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	lastEventIDs := url.Values{}
+	resume := url.Values{}
+	if multi {
+		lastEventIDs, _ = url.ParseQuery(lastEventID)
+		resume, _ = url.ParseQuery(lastEventID)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	conn := newSubscription(done, s.quitChan())
+
+	// Create the stream subscribers
+	for i, stream := range streams {
+		if multi {
+			lastEventID = resume.Get(stream.ID)
 		}
+		conn.reserve(stream.ID)
+		conn.add(stream, stream.addSubscriberWithInfo(lastEventID, r.URL, streamInfos[i]), true)
 	}
 
-	// Create the stream subscriber
-	sub := stream.addSubscriber(eventid, r.URL)
+	// Subscribe to the existing and future streams matching the patterns
+	var pss []*patternSubscription
+	var replayed []streamEvent
+	// Ids of the replayed events, per stream
+	replayedIDs := map[string]map[string]bool{}
+	for _, pattern := range patterns {
+		ps := s.newPatternSubscription(r, pattern, resume, conn)
+		pss = append(pss, ps)
 
+		for _, stream := range s.subscribePattern(ps) {
+			if !conn.reserve(stream.ID) {
+				continue
+			}
+			info, _, err := s.authorize(r, stream.ID)
+			if err != nil {
+				continue
+			}
+
+			events := s.replayPattern(stream, conn, r, info, resume.Get(stream.ID))
+			for _, ev := range events {
+				replayed = append(replayed, streamEvent{stream: stream.ID, event: ev, metrics: stream.metrics})
+				if len(ev.ID) > 0 {
+					if replayedIDs[stream.ID] == nil {
+						replayedIDs[stream.ID] = map[string]bool{}
+					}
+					replayedIDs[stream.ID][string(ev.ID)] = true
+				}
+			}
+		}
+	}
+
+	// Merge the replayed events of all matching streams
+	sort.SliceStable(replayed, func(i, j int) bool {
+		return replayed[i].event.timestamp.Before(replayed[j].event.timestamp)
+	})
+
+	// Remove the subscribers once the client is gone or the connection is
+	// no longer served
 	go func() {
-		<-r.Context().Done()
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+
+		for _, ps := range pss {
+			s.unsubscribePattern(ps)
+		}
 
-		sub.close()
+		streams, subs := conn.close()
+		for i, stream := range streams {
+			subs[i].close()
 
-		if s.AutoStream && !s.AutoReplay && stream.getSubscriberCount() == 0 {
-			s.RemoveStream(streamID)
+			if s.AutoStream && !s.AutoReplay && stream.getSubscriberCount() == 0 {
+				s.RemoveStream(stream.ID)
+			}
 		}
 	}()
 
 	w.WriteHeader(http.StatusOK)
 	flusher.Flush()
 
-	// Push events to client
-	for ev := range sub.connection {
-		// If the data buffer is an empty string abort.
-		if len(ev.Data) == 0 && len(ev.Comment) == 0 {
-			break
-		}
+	var heartbeat <-chan time.Time
+	interval := heartbeatInterval(streams)
+	if len(patterns) > 0 && s.HeartbeatInterval > 0 && (interval == 0 || s.HeartbeatInterval < interval) {
+		interval = s.HeartbeatInterval
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	var reauthorize <-chan time.Time
+	if s.Authorize != nil && s.AuthorizeInterval > 0 {
+		ticker := time.NewTicker(s.AuthorizeInterval)
+		defer ticker.Stop()
+		reauthorize = ticker.C
+	}
+
+	// Connections with patterns end when the server closes, even when no
+	// stream matches
+	var quit chan struct{}
+	if len(patterns) > 0 {
+		quit = conn.quit
+	}
+
+	send := func(se streamEvent) {
+		ev := se.event
 
 		// if the event has expired, dont send it
 		if s.EventTTL != 0 && time.Now().After(ev.timestamp.Add(s.EventTTL)) {
-			continue
+			se.metrics.EventExpired(se.stream)
+			return
 		}
 
-		if len(ev.Data) > 0 {
-			fmt.Fprintf(w, "id: %s\n", ev.ID)
+		ev, span := s.traceDelivery(r.Context(), se.stream, ev)
+
+		cw := &countingWriter{w: w}
+		if !multi {
+			s.writeEvent(cw, ev, ev.ID, "")
+		} else {
+			if len(ev.ID) > 0 && len(ev.Data) > 0 {
+				lastEventIDs.Set(se.stream, string(ev.ID))
+			}
+			s.writeEvent(cw, ev, []byte(lastEventIDs.Encode()), se.stream)
+		}
+		se.metrics.BytesWritten(se.stream, cw.n)
 
-			if s.SplitData {
-				sd := bytes.Split(ev.Data, []byte("\n"))
-				for i := range sd {
-					fmt.Fprintf(w, "data: %s\n", sd[i])
+		flusher.Flush()
+		span.End()
+	}
+
+	for _, se := range replayed {
+		send(se)
+	}
+
+	// Push events to client
+	for {
+		var se streamEvent
+
+		select {
+		case <-heartbeat:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+			continue
+		case <-reauthorize:
+			// Streams attached through a pattern are authorized again too
+			ids := append([]string(nil), streamIDs...)
+			for _, id := range conn.streamIDs() {
+				if !containsString(ids, id) {
+					ids = append(ids, id)
 				}
-			} else {
-				if bytes.HasPrefix(ev.Data, []byte(":")) {
-					fmt.Fprintf(w, "%s\n", ev.Data)
-				} else {
-					fmt.Fprintf(w, "data: %s\n", ev.Data)
+			}
+			for _, streamID := range ids {
+				if _, _, err := s.authorize(r, streamID); err != nil {
+					fmt.Fprintf(w, ": %s\n\n", commentLine(err.Error()))
+					flusher.Flush()
+					return
 				}
 			}
+			continue
+		case <-r.Context().Done():
+			return
+		case <-quit:
+			// Wait for the final events of the subscribers, if any
+			if conn.forwarding() == 0 {
+				return
+			}
+			quit = nil
+			continue
+		case se = <-conn.events:
+		}
+
+		ev := se.event
 
-			if len(ev.Event) > 0 {
-				fmt.Fprintf(w, "event: %s\n", ev.Event)
+		// If the subscription was closed or the event is empty abort.
+		if ev == nil || len(ev.Data) == 0 && len(ev.Comment) == 0 && len(ev.Retry) == 0 {
+			return
+		}
+
+		// Skip the live events already replayed from a matching stream. They
+		// were queued before the replay, so come before any other event.
+		if ids := replayedIDs[se.stream]; ids != nil && len(ev.ID) > 0 {
+			if ids[string(ev.ID)] {
+				continue
 			}
+			delete(replayedIDs, se.stream)
+		}
+
+		send(se)
+	}
+}
 
-			if len(ev.Retry) > 0 {
-				fmt.Fprintf(w, "retry: %s\n", ev.Retry)
+// writeEvent writes an event frame with the given id. The stream field is
+// only written when a stream is given.
+func (s *Server) writeEvent(w io.Writer, ev *Event, id []byte, stream string) {
+	if len(ev.Data) > 0 {
+		fmt.Fprintf(w, "id: %s\n", id)
+
+		if stream != "" {
+			fmt.Fprintf(w, "stream: %s\n", stream)
+		}
+
+		if s.SplitData {
+			sd := bytes.Split(ev.Data, []byte("\n"))
+			for i := range sd {
+				fmt.Fprintf(w, "data: %s\n", sd[i])
 			}
+		} else {
+			if bytes.HasPrefix(ev.Data, []byte(":")) {
+				fmt.Fprintf(w, "%s\n", ev.Data)
+			} else {
+				fmt.Fprintf(w, "data: %s\n", ev.Data)
+			}
+		}
+
+		if len(ev.Event) > 0 {
+			fmt.Fprintf(w, "event: %s\n", ev.Event)
 		}
 
-		if len(ev.Comment) > 0 {
-			fmt.Fprintf(w, ": %s\n", ev.Comment)
+		if s.PropagateTraceContext && len(ev.TraceContext) > 0 {
+			fmt.Fprintf(w, "trace: %s\n", ev.TraceContext)
 		}
+	}
 
-		fmt.Fprint(w, "\n")
+	if len(ev.Retry) > 0 {
+		fmt.Fprintf(w, "retry: %s\n", ev.Retry)
+	}
 
-		flusher.Flush()
+	if len(ev.Comment) > 0 {
+		fmt.Fprintf(w, ": %s\n", ev.Comment)
+	}
+
+	fmt.Fprint(w, "\n")
+}
+
+// resolveStreamIDs returns the streams a request subscribes to. Only the
+// default stream query parameter can name several streams.
+func (s *Server) resolveStreamIDs(r *http.Request) ([]string, error) {
+	if s.StreamIDResolver != nil {
+		id, err := s.StreamIDResolver(r)
+		if err != nil || id == "" {
+			return nil, err
+		}
+		return []string{id}, nil
+	}
+
+	var ids []string
+	for _, id := range r.URL.Query()[DefaultStreamParam] {
+		if id != "" && !containsString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// heartbeatInterval returns the shortest heartbeat interval of the streams
+func heartbeatInterval(streams []*Stream) time.Duration {
+	var interval time.Duration
+	for _, stream := range streams {
+		if stream.HeartbeatInterval > 0 && (interval == 0 || stream.HeartbeatInterval < interval) {
+			interval = stream.HeartbeatInterval
+		}
+	}
+	return interval
+}
+
+// commentLine replaces the line breaks of a comment, which would end it
+func commentLine(comment string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(comment)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+func containsString(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
 	}
+	return false
 }
@@ -5,6 +5,7 @@
 package sse
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -121,6 +122,46 @@ func TestHTTPStreamHandlerEventID(t *testing.T) {
 	assert.Equal(t, []byte("test 3"), msg)
 }
 
+func TestHTTPStreamHandlerOpaqueEventID(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	s.IDGenerator = PassthroughIDGenerator
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.ServeHTTP)
+	server := httptest.NewServer(mux)
+
+	s.CreateStream("test")
+
+	s.Publish("test", &Event{ID: []byte("01F8MECHZX"), Data: []byte("test 1")})
+	s.Publish("test", &Event{ID: []byte("01F8MECHZY"), Data: []byte("test 2")})
+	s.Publish("test", &Event{ID: []byte("01F8MECHZZ"), Data: []byte("test 3")})
+
+	time.Sleep(time.Millisecond * 100)
+
+	c := NewClient(server.URL + "/events")
+	c.LastEventID.Store([]byte("01F8MECHZY"))
+
+	events := make(chan *Event)
+	go func() {
+		_ = c.Subscribe("test", func(msg *Event) {
+			if len(msg.Data) > 0 {
+				events <- msg
+			}
+		})
+	}()
+
+	ev, err := waitEvent(events, time.Millisecond*500)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("01F8MECHZY"), ev.ID)
+	assert.Equal(t, []byte("test 2"), ev.Data)
+
+	ev, err = waitEvent(events, time.Millisecond*500)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("01F8MECHZZ"), ev.ID)
+}
+
 func TestHTTPStreamHandlerEventTTL(t *testing.T) {
 	s := New()
 	defer s.Close()
@@ -225,3 +266,144 @@ func TestHTTPStreamHandlerAutoStream(t *testing.T) {
 
 	assert.Equal(t, (*Stream)(nil), sseServer.getStream("test"))
 }
+
+func TestHTTPStreamHandlerHeartbeat(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	s.HeartbeatInterval = time.Millisecond * 50
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.ServeHTTP)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	resp, err := http.Get(server.URL + "/events?stream=test")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	reader := NewEventStreamReader(resp.Body, 1<<16)
+	msg, err := reader.ReadEvent()
+	require.Nil(t, err)
+	assert.Equal(t, []byte(": keepalive"), msg)
+}
+
+func TestHTTPStreamHandlerMultiStream(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.ServeHTTP)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s.CreateStream("a")
+	s.CreateStream("b")
+
+	s.Publish("a", &Event{Data: []byte("a 1")})
+	s.Publish("a", &Event{Data: []byte("a 2")})
+	s.Publish("b", &Event{Data: []byte("b 1")})
+	time.Sleep(time.Millisecond * 100)
+
+	req, err := http.NewRequest("GET", server.URL+"/events?stream=a&stream=b", nil)
+	require.Nil(t, err)
+	req.Header.Set("Last-Event-ID", "a=1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	c := NewClient("")
+	reader := NewEventStreamReader(resp.Body, 1<<16)
+
+	received := map[string]*Event{}
+	for i := 0; i < 2; i++ {
+		msg, err := reader.ReadEvent()
+		require.Nil(t, err)
+		ev, err := c.processEvent(msg)
+		require.Nil(t, err)
+		received[string(ev.Stream)] = ev
+	}
+
+	require.Contains(t, received, "a")
+	require.Contains(t, received, "b")
+	assert.Equal(t, []byte("a 2"), received["a"].Data)
+	assert.Equal(t, []byte("b 1"), received["b"].Data)
+
+	s.Publish("b", &Event{Data: []byte("b 2")})
+
+	msg, err := reader.ReadEvent()
+	require.Nil(t, err)
+	ev, err := c.processEvent(msg)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("b"), ev.Stream)
+	assert.Equal(t, []byte("a=1&b=1"), ev.ID)
+}
+
+// stalledWriter is a response writer whose writes block until the request
+// is canceled, like the connection of a client that stopped reading
+type stalledWriter struct {
+	header http.Header
+	ctx    context.Context
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+
+func (w *stalledWriter) WriteHeader(int) {}
+
+func (w *stalledWriter) Flush() {}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	<-w.ctx.Done()
+	return 0, w.ctx.Err()
+}
+
+func TestHTTPStreamHandlerSlowClientDisconnect(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	stream := s.CreateStream("test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/events?stream=test", nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		s.ServeHTTP(&stalledWriter{header: http.Header{}, ctx: ctx}, r)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return stream.getSubscriberCount() == 1 }, time.Second, time.Millisecond*10)
+
+	// Fill the subscriber's buffer so the stream blocks on it
+	for i := 0; i < 200; i++ {
+		s.TryPublish("test", &Event{Data: []byte("test")})
+	}
+
+	cancel()
+	<-done
+
+	require.Eventually(t, func() bool { return stream.getSubscriberCount() == 0 }, time.Second, time.Millisecond*10)
+
+	// The stream still delivers to other subscribers
+	sub := stream.addSubscriber("", nil)
+	published := make(chan struct{})
+	go func() {
+		s.Publish("test", &Event{Data: []byte("after")})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked")
+	}
+
+	for ev := range sub.connection {
+		if string(ev.Data) == "after" {
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"crypto/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator returns the id of an event published to a stream. When a
+// generator returns an empty id, the stream's event store assigns the next
+// sequential id instead.
+type IDGenerator func(ev *Event) []byte
+
+// crockford is the base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// PassthroughIDGenerator keeps the id an event was published with
+func PassthroughIDGenerator(ev *Event) []byte {
+	return ev.ID
+}
+
+// NewSequentialIDGenerator returns a generator of increasing integer ids
+// starting at start
+func NewSequentialIDGenerator(start int) IDGenerator {
+	var mu sync.Mutex
+	next := start
+
+	return func(ev *Event) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := next
+		next++
+		return []byte(strconv.Itoa(id))
+	}
+}
+
+// NewTimestampIDGenerator returns a generator of ids holding the unix time in
+// nanoseconds. Ids are strictly increasing, even when generated within the
+// same nanosecond.
+func NewTimestampIDGenerator() IDGenerator {
+	var mu sync.Mutex
+	var last int64
+
+	return func(ev *Event) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now().UnixNano()
+		if now <= last {
+			now = last + 1
+		}
+		last = now
+		return []byte(strconv.FormatInt(now, 10))
+	}
+}
+
+// NewULIDGenerator returns a generator of ULIDs. Ids generated within the same
+// millisecond are monotonic.
+func NewULIDGenerator() IDGenerator {
+	var mu sync.Mutex
+	var lastTime uint64
+	var entropy [10]byte
+
+	return func(ev *Event) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+		if ms <= lastTime {
+			ms = lastTime
+			incrementEntropy(&entropy)
+		} else {
+			_, _ = rand.Read(entropy[:])
+		}
+		lastTime = ms
+
+		return encodeULID(ms, entropy)
+	}
+}
+
+// incrementEntropy adds one to the random part of an ULID
+func incrementEntropy(entropy *[10]byte) {
+	for i := len(entropy) - 1; i >= 0; i-- {
+		entropy[i]++
+		if entropy[i] != 0 {
+			return
+		}
+	}
+}
+
+// encodeULID encodes a 48 bit timestamp and 80 bits of entropy into 26
+// crockford base32 characters
+func encodeULID(ms uint64, entropy [10]byte) []byte {
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> uint(40-8*i))
+	}
+	copy(id[6:], entropy[:])
+
+	out := make([]byte, 26)
+	// 128 bits are encoded in 130 bits, the first character holds 3 bits
+	var acc uint32
+	bits := 2
+	pos := 0
+	for i := 0; i < len(id); i++ {
+		acc = acc<<8 | uint32(id[i])
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>uint(bits))&31]
+			pos++
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,60 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassthroughIDGenerator(t *testing.T) {
+	ev := &Event{ID: []byte("offset-42"), Data: []byte("test")}
+	assert.Equal(t, []byte("offset-42"), PassthroughIDGenerator(ev))
+}
+
+func TestSequentialIDGenerator(t *testing.T) {
+	gen := NewSequentialIDGenerator(10)
+
+	for i := 10; i < 15; i++ {
+		assert.Equal(t, []byte(strconv.Itoa(i)), gen(&Event{}))
+	}
+}
+
+func TestTimestampIDGenerator(t *testing.T) {
+	gen := NewTimestampIDGenerator()
+
+	var last int64
+	for i := 0; i < 100; i++ {
+		id, err := strconv.ParseInt(string(gen(&Event{})), 10, 64)
+		assert.Nil(t, err)
+		assert.True(t, id > last)
+		last = id
+	}
+}
+
+func TestULIDGenerator(t *testing.T) {
+	gen := NewULIDGenerator()
+
+	var last []byte
+	for i := 0; i < 100; i++ {
+		id := gen(&Event{})
+		assert.Len(t, id, 26)
+		assert.True(t, bytes.Compare(id, last) > 0)
+		last = id
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var entropy [10]byte
+	assert.Equal(t, []byte("00000000000000000000000000"), encodeULID(0, entropy))
+
+	for i := range entropy {
+		entropy[i] = 0xff
+	}
+	assert.Equal(t, []byte("7ZZZZZZZZZZZZZZZZZZZZZZZZZ"), encodeULID(1<<48-1, entropy))
+}
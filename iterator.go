@@ -0,0 +1,177 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+// ErrIteratorClosed is returned by Next once the iterator is closed
+var ErrIteratorClosed = errors.New("event iterator closed")
+
+// EventIterator reads the events of a stream one at a time. Events are only
+// read from the connection when Next is called, so a slow reader slows the
+// server down instead of buffering events.
+type EventIterator struct {
+	client *Client
+	stream string
+	ctx    context.Context
+	cancel context.CancelFunc
+	resp   *http.Response
+	reader *EventStreamReader
+	// result of a read still in progress, when Next returned early
+	pending chan readResult
+}
+
+type readResult struct {
+	msg *Event
+	err error
+}
+
+// Stream connects to a stream and returns an iterator over its events.
+// Connection errors are returned right away, later ones by Next.
+func (c *Client) Stream(ctx context.Context, stream string) (*EventIterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	it := &EventIterator{
+		client: c,
+		stream: stream,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	c.setState(StateConnecting, nil)
+
+	if err := it.connect(); err != nil {
+		cancel()
+		err = permanentError(err)
+		c.setState(StateClosed, err)
+		return nil, err
+	}
+
+	return it, nil
+}
+
+// Next blocks until the next event is received. When the connection fails,
+// it reconnects following the client's reconnect strategy and returns the
+// error once the strategy gives up. io.EOF is returned when the server ends
+// the stream, and ErrEventTooLarge when an event larger than the client's
+// maximum size is skipped.
+func (it *EventIterator) Next(ctx context.Context) (*Event, error) {
+	for {
+		if it.pending == nil {
+			it.pending = make(chan readResult, 1)
+			go it.read(it.reader, it.pending)
+		}
+
+		var res readResult
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-it.ctx.Done():
+			return nil, ErrIteratorClosed
+		case res = <-it.pending:
+			it.pending = nil
+		}
+
+		if res.err == nil {
+			if res.msg != nil {
+				return res.msg, nil
+			}
+			continue
+		}
+
+		if res.err == io.EOF {
+			it.client.setState(StateClosed, nil)
+			return nil, io.EOF
+		}
+
+		// The event was skipped, the next one can still be read
+		if res.err == ErrEventTooLarge {
+			return nil, res.err
+		}
+
+		if err := it.reconnect(ctx, res.err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the connection, pending and later calls to Next return
+// ErrIteratorClosed
+func (it *EventIterator) Close() error {
+	it.cancel()
+	it.client.setState(StateClosed, nil)
+	return it.resp.Body.Close()
+}
+
+// read reads a single event
+func (it *EventIterator) read(reader *EventStreamReader, out chan readResult) {
+	event, err := reader.ReadEvent()
+	if err != nil {
+		out <- readResult{err: err}
+		return
+	}
+	out <- readResult{msg: it.client.parseEvent(event)}
+}
+
+func (it *EventIterator) connect() error {
+	resp, err := it.client.connect(it.ctx, it.stream)
+	if err != nil {
+		return err
+	}
+
+	it.resp = resp
+	it.reader = NewEventStreamReader(resp.Body, it.client.maxBufferSize)
+
+	it.client.setState(StateOpen, nil)
+
+	return nil
+}
+
+// reconnect connects again after a failure, waiting between attempts as
+// told by the client's reconnect strategy
+func (it *EventIterator) reconnect(ctx context.Context, err error) error {
+	it.resp.Body.Close()
+
+	strategy := it.client.reconnectStrategy()
+	strategy.Reset()
+
+	for {
+		if permanent, ok := err.(*backoff.PermanentError); ok {
+			it.client.setState(StateClosed, permanent.Err)
+			return permanent.Err
+		}
+
+		next := strategy.NextBackOff()
+		if next == backoff.Stop {
+			it.client.setState(StateClosed, err)
+			return err
+		}
+
+		it.client.reconnectNotify()(err, next)
+
+		timer := time.NewTimer(next)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-it.ctx.Done():
+			timer.Stop()
+			return ErrIteratorClosed
+		case <-timer.C:
+		}
+
+		if err = it.connect(); err == nil {
+			return nil
+		}
+	}
+}
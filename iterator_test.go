@@ -0,0 +1,113 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+func TestClientStream(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+	s.Publish("test", &Event{Data: []byte("first")})
+
+	c := NewClient(server.URL)
+	it, err := c.Stream(context.Background(), "test")
+	require.Nil(t, err)
+
+	ev, err := it.Next(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []byte("first"), ev.Data)
+
+	// A timed out call leaves the event to the next one
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = it.Next(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	s.Publish("test", &Event{Data: []byte("second")})
+
+	ev, err = it.Next(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []byte("second"), ev.Data)
+
+	require.Nil(t, it.Close())
+	_, err = it.Next(context.Background())
+	assert.Equal(t, ErrIteratorClosed, err)
+}
+
+func TestClientStreamConnectError(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	_, err := c.Stream(context.Background(), "missing")
+	assert.NotNil(t, err)
+}
+
+func TestClientStreamReadError(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", s)
+	server := httptest.NewServer(mux)
+
+	s.CreateStream("test")
+
+	c := NewClient(server.URL + "/events")
+	c.ReconnectStrategy = &backoff.StopBackOff{}
+
+	it, err := c.Stream(context.Background(), "test")
+	require.Nil(t, err)
+	defer it.Close()
+
+	server.CloseClientConnections()
+	server.Close()
+
+	_, err = it.Next(context.Background())
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrIteratorClosed, err)
+}
+
+func TestClientStreamEventTooLarge(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+	s.Publish("test", &Event{Data: make([]byte, 1<<11)})
+	s.Publish("test", &Event{Data: []byte("small")})
+
+	c := NewClient(server.URL, ClientMaxBufferSize(1<<10))
+	it, err := c.Stream(context.Background(), "test")
+	require.Nil(t, err)
+	defer it.Close()
+
+	_, err = it.Next(context.Background())
+	assert.Equal(t, ErrEventTooLarge, err)
+
+	ev, err := it.Next(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []byte("small"), ev.Data)
+}
@@ -0,0 +1,115 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+var eventPtrType = reflect.TypeOf(&Event{})
+
+// PublishJSON publishes the JSON encoding of v to a stream, as an event of
+// the given type. Unnamed events are published when eventType is empty.
+func (s *Server) PublishJSON(id, eventType string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ev := &Event{Data: data}
+	if eventType != "" {
+		ev.Event = []byte(eventType)
+	}
+
+	s.Publish(id, ev)
+
+	return nil
+}
+
+// JSONHandler returns an event handler that decodes the data of each event
+// as JSON into a new value of the type taken by fn, then calls fn with it.
+// fn must be a func(T) or func(T, *Event), T being a value or pointer type.
+// Events that cannot be decoded are passed to onError, if set. It panics if
+// fn is not of that form.
+func JSONHandler(fn interface{}, onError func(msg *Event, err error)) func(msg *Event) {
+	handler, err := newJSONHandler(fn, onError)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+// SubscribeJSON to a data stream, decoding events with the handler of their
+// event name as with JSONHandler. Unnamed events are handled by the
+// "message" handler, events without a handler are ignored.
+func (c *Client) SubscribeJSON(stream string, handlers map[string]interface{}, onError func(msg *Event, err error)) error {
+	return c.SubscribeJSONWithContext(context.Background(), stream, handlers, onError)
+}
+
+// SubscribeJSONWithContext is the same as SubscribeJSON with context
+func (c *Client) SubscribeJSONWithContext(ctx context.Context, stream string, handlers map[string]interface{}, onError func(msg *Event, err error)) error {
+	decoders := make(map[string]func(msg *Event), len(handlers))
+	for name, fn := range handlers {
+		handler, err := newJSONHandler(fn, onError)
+		if err != nil {
+			return fmt.Errorf("handler of %q events: %s", name, err)
+		}
+		decoders[name] = handler
+	}
+
+	return c.SubscribeWithContext(ctx, stream, func(msg *Event) {
+		name := string(msg.Event)
+		if name == "" {
+			name = defaultEventType
+		}
+		if handler := decoders[name]; handler != nil {
+			handler(msg)
+		}
+	})
+}
+
+func newJSONHandler(fn interface{}, onError func(msg *Event, err error)) (func(msg *Event), error) {
+	if fn == nil {
+		return nil, fmt.Errorf("expected a func(T) or func(T, *sse.Event), got nil")
+	}
+
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+
+	if t.Kind() != reflect.Func || t.NumOut() != 0 || t.NumIn() < 1 || t.NumIn() > 2 || t.NumIn() == 2 && t.In(1) != eventPtrType {
+		return nil, fmt.Errorf("expected a func(T) or func(T, *sse.Event), got %s", t)
+	}
+
+	target := t.In(0)
+
+	return func(msg *Event) {
+		var value reflect.Value
+		if target.Kind() == reflect.Ptr {
+			value = reflect.New(target.Elem())
+		} else {
+			value = reflect.New(target)
+		}
+
+		if err := json.Unmarshal(msg.Data, value.Interface()); err != nil {
+			if onError != nil {
+				onError(msg, err)
+			}
+			return
+		}
+
+		if target.Kind() != reflect.Ptr {
+			value = value.Elem()
+		}
+
+		args := []reflect.Value{value}
+		if t.NumIn() == 2 {
+			args = append(args, reflect.ValueOf(msg))
+		}
+		v.Call(args)
+	}, nil
+}
@@ -0,0 +1,114 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testOrder struct {
+	ID    int    `json:"id"`
+	Notes string `json:"notes"`
+}
+
+func TestJSONHandler(t *testing.T) {
+	var byValue testOrder
+	JSONHandler(func(o testOrder) {
+		byValue = o
+	}, nil)(&Event{Data: []byte(`{"id":1}`)})
+	assert.Equal(t, 1, byValue.ID)
+
+	var byPointer *testOrder
+	var event *Event
+	ev := &Event{Data: []byte("{\"id\":2,\n\"notes\":\"a\\nb\"}"), Event: []byte("order")}
+	JSONHandler(func(o *testOrder, msg *Event) {
+		byPointer = o
+		event = msg
+	}, nil)(ev)
+	require.NotNil(t, byPointer)
+	assert.Equal(t, testOrder{ID: 2, Notes: "a\nb"}, *byPointer)
+	assert.Equal(t, ev, event)
+
+	var decodeErr error
+	JSONHandler(func(o testOrder) {
+		t.Fatal("handler called with invalid data")
+	}, func(msg *Event, err error) {
+		decodeErr = err
+	})(&Event{Data: []byte("not json")})
+	assert.NotNil(t, decodeErr)
+
+	assert.Panics(t, func() { JSONHandler(func() {}, nil) })
+	assert.Panics(t, func() { JSONHandler(func(o testOrder, s string) {}, nil) })
+	assert.Panics(t, func() { JSONHandler("handler", nil) })
+	assert.Panics(t, func() { JSONHandler(nil, nil) })
+}
+
+func TestSubscribeJSON(t *testing.T) {
+	s := New()
+	s.SplitData = true
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+	require.Nil(t, s.PublishJSON("test", "order", testOrder{ID: 1, Notes: "multi\nline"}))
+	require.Nil(t, s.PublishJSON("test", "", "unnamed"))
+	require.Nil(t, s.PublishJSON("test", "ignored", 42))
+	s.Publish("test", &Event{Data: []byte("{broken"), Event: []byte("order")})
+	assert.NotNil(t, s.PublishJSON("test", "order", func() {}))
+
+	orders := make(chan testOrder, 1)
+	messages := make(chan string, 1)
+	errs := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewClient(server.URL)
+	go c.SubscribeJSONWithContext(ctx, "test", map[string]interface{}{
+		"order": func(o testOrder) {
+			orders <- o
+		},
+		"message": func(m string) {
+			messages <- m
+		},
+	}, func(msg *Event, err error) {
+		errs <- err
+	})
+
+	select {
+	case o := <-orders:
+		assert.Equal(t, testOrder{ID: 1, Notes: "multi\nline"}, o)
+	case <-time.After(time.Second):
+		t.Fatal("order not received")
+	}
+
+	select {
+	case m := <-messages:
+		assert.Equal(t, "unnamed", m)
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+
+	select {
+	case err := <-errs:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("decode error not reported")
+	}
+}
+
+func TestSubscribeJSONInvalidHandler(t *testing.T) {
+	c := NewClient("http://localhost")
+	err := c.SubscribeJSON("test", map[string]interface{}{"order": func() {}}, nil)
+	assert.NotNil(t, err)
+}
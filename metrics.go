@@ -0,0 +1,124 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import "sync"
+
+// Metrics receives measurements of the server's streams and subscribers.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// SubscriberAdded is called when a subscriber is attached to a stream
+	SubscriberAdded(streamID string)
+	// SubscriberRemoved is called when a subscriber is removed from a stream
+	SubscriberRemoved(streamID string)
+	// EventPublished is called when a stream fans an event out to its subscribers
+	EventPublished(streamID string)
+	// EventDropped is called when an event is not delivered to a subscriber,
+	// because TryPublish would block or the slow subscriber policy fired
+	EventDropped(streamID string)
+	// EventsReplayed is called with the number of logged events replayed to
+	// a new subscriber
+	EventsReplayed(streamID string, n int)
+	// EventExpired is called when an event is not sent because its EventTTL
+	// has passed
+	EventExpired(streamID string)
+	// EventsEvicted is called with the number of events evicted from a
+	// bounded event log
+	EventsEvicted(streamID string, n int)
+	// BytesWritten is called with the number of bytes of an event written
+	// to a subscriber's connection
+	BytesWritten(streamID string, n int)
+	// BufferedEvents is called with the number of events waiting in a
+	// stream's buffer, each time an event is taken from it
+	BufferedEvents(streamID string, n int)
+	// StreamRemoved is called once a stream removed by RemoveStream, Close or
+	// Shutdown has stopped, after its last measurement
+	StreamRemoved(streamID string)
+}
+
+// nopMetrics discards all measurements
+type nopMetrics struct{}
+
+func (nopMetrics) SubscriberAdded(streamID string)       {}
+func (nopMetrics) SubscriberRemoved(streamID string)     {}
+func (nopMetrics) EventPublished(streamID string)        {}
+func (nopMetrics) EventDropped(streamID string)          {}
+func (nopMetrics) EventsReplayed(streamID string, n int) {}
+func (nopMetrics) EventExpired(streamID string)          {}
+func (nopMetrics) EventsEvicted(streamID string, n int)  {}
+func (nopMetrics) BytesWritten(streamID string, n int)   {}
+func (nopMetrics) BufferedEvents(streamID string, n int) {}
+func (nopMetrics) StreamRemoved(streamID string)         {}
+
+// removableMetrics forwards the measurements of a single stream until it is
+// removed, so that StreamRemoved is the last call made for the stream even
+// when other goroutines still hold it
+type removableMetrics struct {
+	Metrics
+
+	mu      sync.RWMutex
+	removed bool
+}
+
+func newRemovableMetrics(m Metrics) *removableMetrics {
+	return &removableMetrics{Metrics: m}
+}
+
+// forward calls fn unless the stream has been removed
+func (m *removableMetrics) forward(fn func()) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.removed {
+		fn()
+	}
+}
+
+func (m *removableMetrics) SubscriberAdded(streamID string) {
+	m.forward(func() { m.Metrics.SubscriberAdded(streamID) })
+}
+
+func (m *removableMetrics) SubscriberRemoved(streamID string) {
+	m.forward(func() { m.Metrics.SubscriberRemoved(streamID) })
+}
+
+func (m *removableMetrics) EventPublished(streamID string) {
+	m.forward(func() { m.Metrics.EventPublished(streamID) })
+}
+
+func (m *removableMetrics) EventDropped(streamID string) {
+	m.forward(func() { m.Metrics.EventDropped(streamID) })
+}
+
+func (m *removableMetrics) EventsReplayed(streamID string, n int) {
+	m.forward(func() { m.Metrics.EventsReplayed(streamID, n) })
+}
+
+func (m *removableMetrics) EventExpired(streamID string) {
+	m.forward(func() { m.Metrics.EventExpired(streamID) })
+}
+
+func (m *removableMetrics) EventsEvicted(streamID string, n int) {
+	m.forward(func() { m.Metrics.EventsEvicted(streamID, n) })
+}
+
+func (m *removableMetrics) BytesWritten(streamID string, n int) {
+	m.forward(func() { m.Metrics.BytesWritten(streamID, n) })
+}
+
+func (m *removableMetrics) BufferedEvents(streamID string, n int) {
+	m.forward(func() { m.Metrics.BufferedEvents(streamID, n) })
+}
+
+// StreamRemoved forwards the removal once and drops later measurements
+func (m *removableMetrics) StreamRemoved(streamID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.removed {
+		m.removed = true
+		m.Metrics.StreamRemoved(streamID)
+	}
+}
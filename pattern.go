@@ -0,0 +1,282 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Tokens of stream patterns. Stream ids are split into tokens on dots, "*"
+// matches a single token and ">" matches one or more trailing tokens, e.g.
+// "orders.eu.*" matches "orders.eu.123" and "orders.>" matches both
+// "orders.eu" and "orders.eu.123".
+const (
+	tokenSeparator = "."
+	tokenWildcard  = "*"
+	tokenTail      = ">"
+)
+
+// isStreamPattern reports whether a stream id contains wildcard tokens
+func isStreamPattern(id string) bool {
+	for _, token := range strings.Split(id, tokenSeparator) {
+		if token == tokenWildcard || token == tokenTail {
+			return true
+		}
+	}
+	return false
+}
+
+// validStreamPattern reports whether ">" is only used as the last token
+func validStreamPattern(pattern string) bool {
+	tokens := strings.Split(pattern, tokenSeparator)
+	for i, token := range tokens {
+		if token == tokenTail && i != len(tokens)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// matchStream reports whether a stream id matches a pattern
+func matchStream(pattern, id string) bool {
+	pt := strings.Split(pattern, tokenSeparator)
+	it := strings.Split(id, tokenSeparator)
+
+	for i, token := range pt {
+		if token == tokenTail {
+			return i == len(pt)-1 && len(it) > i
+		}
+		if i >= len(it) || token != tokenWildcard && token != it[i] {
+			return false
+		}
+	}
+
+	return len(pt) == len(it)
+}
+
+// patternSubscription attaches a connection to the streams matching its
+// pattern as they are created
+type patternSubscription struct {
+	pattern string
+	attach  func(str *Stream)
+}
+
+// newPatternSubscription returns a pattern subscription attaching a
+// connection to the new matching streams the request is authorized for
+func (s *Server) newPatternSubscription(r *http.Request, pattern string, resume url.Values, conn *subscription) *patternSubscription {
+	return &patternSubscription{
+		pattern: pattern,
+		attach: func(str *Stream) {
+			if !conn.reserve(str.ID) {
+				return
+			}
+			info, _, err := s.authorize(r, str.ID)
+			if err != nil {
+				return
+			}
+			conn.add(str, str.addSubscriberWithInfo(resume.Get(str.ID), r.URL, info), false)
+		},
+	}
+}
+
+// replayPattern attaches a connection to an existing stream matching its
+// pattern. Instead of being queued, the stream's logged events are returned
+// to be merged with those of the other matching streams.
+func (s *Server) replayPattern(str *Stream, conn *subscription, r *http.Request, info SubscriberInfo, eventid string) []*Event {
+	sub := str.subscribe(&Subscriber{
+		eventid:    eventid,
+		skipReplay: true,
+		URL:        r.URL,
+		Info:       info,
+		EventTypes: eventTypes(r.URL),
+	})
+	conn.add(str, sub, false)
+
+	if !str.AutoReplay {
+		return nil
+	}
+
+	events, err := str.Eventlog.ReplayFrom(eventid)
+	if err != nil {
+		str.reportError(err)
+		return nil
+	}
+
+	replayed := make([]*Event, 0, len(events))
+	for _, ev := range events {
+		if str.accepts(sub, ev) {
+			replayed = append(replayed, ev)
+		}
+	}
+
+	str.metrics.EventsReplayed(str.ID, len(replayed))
+
+	return replayed
+}
+
+// subscribePattern registers a pattern subscription and returns the
+// existing streams matching it
+func (s *Server) subscribePattern(ps *patternSubscription) []*Stream {
+	s.muStreams.Lock()
+	defer s.muStreams.Unlock()
+
+	if s.patterns == nil {
+		s.patterns = make(map[*patternSubscription]struct{})
+	}
+	s.patterns[ps] = struct{}{}
+
+	var streams []*Stream
+	for id, str := range s.streams {
+		if matchStream(ps.pattern, id) {
+			streams = append(streams, str)
+		}
+	}
+
+	return streams
+}
+
+// unsubscribePattern stops attaching a pattern subscription to new streams
+func (s *Server) unsubscribePattern(ps *patternSubscription) {
+	s.muStreams.Lock()
+	defer s.muStreams.Unlock()
+
+	delete(s.patterns, ps)
+}
+
+// matchingPatterns returns the pattern subscriptions matching a stream id.
+// The caller must hold muStreams.
+func (s *Server) matchingPatterns(id string) []*patternSubscription {
+	var matches []*patternSubscription
+	for ps := range s.patterns {
+		if matchStream(ps.pattern, id) {
+			matches = append(matches, ps)
+		}
+	}
+	return matches
+}
+
+// subscription merges the events of the subscribers serving a single
+// connection into one channel
+type subscription struct {
+	events chan streamEvent
+	// closed when the connection is served
+	done chan struct{}
+	// closed when the server is closed or shut down
+	quit chan struct{}
+	// number of subscribers whose events are being forwarded
+	active int32
+
+	streams  []*Stream
+	subs     []*Subscriber
+	attached map[string]bool
+	closed   bool
+	mu       sync.Mutex
+}
+
+func newSubscription(done, quit chan struct{}) *subscription {
+	return &subscription{
+		events:   make(chan streamEvent),
+		done:     done,
+		quit:     quit,
+		attached: make(map[string]bool),
+	}
+}
+
+// reserve marks a stream as attached to the connection, it returns false if
+// it already was
+func (c *subscription) reserve(streamID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.attached[streamID] {
+		return false
+	}
+	c.attached[streamID] = true
+
+	return true
+}
+
+// add forwards the events of a subscriber. A nil event is sent once the
+// subscription of a required subscriber is closed. Other subscribers, those
+// attached through a pattern, only end the connection when the server is
+// closing. Once the connection is served, the subscriber's events are
+// discarded until the stream removes it, so the stream never blocks on it.
+func (c *subscription) add(stream *Stream, sub *Subscriber, required bool) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		go discard(sub)
+		sub.close()
+		return
+	}
+	c.streams = append(c.streams, stream)
+	c.subs = append(c.subs, sub)
+	atomic.AddInt32(&c.active, 1)
+	c.mu.Unlock()
+
+	go func() {
+		defer atomic.AddInt32(&c.active, -1)
+
+		for ev := range sub.connection {
+			select {
+			case c.events <- streamEvent{stream: stream.ID, event: ev, metrics: stream.metrics}:
+			case <-c.done:
+				discard(sub)
+				return
+			}
+		}
+
+		if !required {
+			select {
+			case <-c.quit:
+			default:
+				return
+			}
+		}
+
+		select {
+		case c.events <- streamEvent{stream: stream.ID}:
+		case <-c.done:
+		}
+	}()
+}
+
+// discard reads the events of a subscriber until the stream removes it
+func discard(sub *Subscriber) {
+	for range sub.connection {
+	}
+}
+
+// close returns the streams and subscribers of the connection, later
+// subscribers are closed as soon as they are added
+func (c *subscription) close() ([]*Stream, []*Subscriber) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	return c.streams, c.subs
+}
+
+// streamIDs returns the ids of the streams whose subscribers were added
+func (c *subscription) streamIDs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]string, len(c.streams))
+	for i := range c.streams {
+		ids[i] = c.streams[i].ID
+	}
+	return ids
+}
+
+// forwarding returns the number of subscribers whose events are forwarded
+func (c *subscription) forwarding() int {
+	return int(atomic.LoadInt32(&c.active))
+}
@@ -0,0 +1,211 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchStream(t *testing.T) {
+	tests := []struct {
+		pattern, id string
+		match       bool
+	}{
+		{"orders.eu.*", "orders.eu.123", true},
+		{"orders.eu.*", "orders.eu", false},
+		{"orders.eu.*", "orders.eu.123.items", false},
+		{"orders.*.123", "orders.us.123", true},
+		{"orders.>", "orders.eu", true},
+		{"orders.>", "orders.eu.123", true},
+		{"orders.>", "orders", false},
+		{"orders.>", "users.eu", false},
+		{">", "orders", true},
+		{"orders.eu", "orders.eu", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, matchStream(test.pattern, test.id), "%s ~ %s", test.pattern, test.id)
+	}
+
+	assert.True(t, isStreamPattern("orders.*"))
+	assert.True(t, isStreamPattern("orders.>"))
+	assert.False(t, isStreamPattern("orders.eu*"))
+	assert.False(t, validStreamPattern("orders.>.eu"))
+}
+
+func TestHTTPStreamHandlerPattern(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("orders.eu.1")
+	s.CreateStream("orders.eu.2")
+	s.CreateStream("orders.us.1")
+
+	s.Publish("orders.eu.1", &Event{Data: []byte("eu.1 first")})
+	time.Sleep(time.Millisecond * 10)
+	s.Publish("orders.eu.2", &Event{Data: []byte("eu.2 first")})
+	time.Sleep(time.Millisecond * 10)
+	s.Publish("orders.us.1", &Event{Data: []byte("us.1 first")})
+	time.Sleep(time.Millisecond * 10)
+	s.Publish("orders.eu.1", &Event{Data: []byte("eu.1 second")})
+	time.Sleep(time.Millisecond * 100)
+
+	resp, err := http.Get(server.URL + "?stream=" + url.QueryEscape("orders.eu.*"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	c := NewClient("")
+	reader := NewEventStreamReader(resp.Body, 1<<16)
+	next := func() *Event {
+		msg, err := reader.ReadEvent()
+		require.Nil(t, err)
+		ev, err := c.processEvent(msg)
+		require.Nil(t, err)
+		return ev
+	}
+
+	// Replayed events are merged in publishing order
+	for _, data := range []string{"eu.1 first", "eu.2 first", "eu.1 second"} {
+		ev := next()
+		assert.Equal(t, data, string(ev.Data))
+	}
+
+	// Live events of existing and future streams
+	s.Publish("orders.us.1", &Event{Data: []byte("us.1 second")})
+	s.Publish("orders.eu.2", &Event{Data: []byte("eu.2 second")})
+
+	ev := next()
+	assert.Equal(t, []byte("eu.2 second"), ev.Data)
+	assert.Equal(t, []byte("orders.eu.2"), ev.Stream)
+
+	s.CreateStream("orders.eu.3")
+	s.Publish("orders.eu.3", &Event{Data: []byte("eu.3 first")})
+
+	ev = next()
+	assert.Equal(t, []byte("eu.3 first"), ev.Data)
+	assert.Equal(t, []byte("orders.eu.3"), ev.Stream)
+
+	ids, err := url.ParseQuery(string(ev.ID))
+	require.Nil(t, err)
+	assert.Equal(t, "1", ids.Get("orders.eu.1"))
+	assert.Equal(t, "1", ids.Get("orders.eu.2"))
+	assert.Equal(t, "0", ids.Get("orders.eu.3"))
+}
+
+func TestHTTPStreamHandlerPatternResume(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("orders.eu")
+	s.Publish("orders.eu", &Event{Data: []byte("first")})
+	s.Publish("orders.eu", &Event{Data: []byte("second")})
+	time.Sleep(time.Millisecond * 100)
+
+	req, err := http.NewRequest("GET", server.URL+"?stream=orders.%3E", nil)
+	require.Nil(t, err)
+	req.Header.Set("Last-Event-ID", "orders.eu=1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	c := NewClient("")
+	reader := NewEventStreamReader(resp.Body, 1<<16)
+
+	msg, err := reader.ReadEvent()
+	require.Nil(t, err)
+	ev, err := c.processEvent(msg)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("second"), ev.Data)
+
+	s.Publish("orders.eu", &Event{Data: []byte("third")})
+
+	msg, err = reader.ReadEvent()
+	require.Nil(t, err)
+	ev, err = c.processEvent(msg)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("third"), ev.Data)
+}
+
+func TestHTTPStreamHandlerPatternWithoutReplay(t *testing.T) {
+	s := New()
+	s.AutoReplay = false
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("orders.eu.1")
+	s.Publish("orders.eu.1", &Event{Data: []byte("before")})
+
+	resp, err := http.Get(server.URL + "?stream=" + url.QueryEscape("orders.eu.*"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	s.Publish("orders.eu.1", &Event{Data: []byte("live")})
+
+	c := NewClient("")
+	reader := NewEventStreamReader(resp.Body, 1<<16)
+
+	msg, err := reader.ReadEvent()
+	require.Nil(t, err)
+	ev, err := c.processEvent(msg)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("live"), ev.Data)
+}
+
+func TestHTTPStreamHandlerPatternClose(t *testing.T) {
+	s := New()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?stream=orders.*")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	s.Close()
+
+	reader := NewEventStreamReader(resp.Body, 1<<16)
+	done := make(chan error)
+	go func() {
+		_, err := reader.ReadEvent()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("connection was not closed")
+	}
+}
+
+func TestHTTPStreamHandlerInvalidPattern(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?stream=" + url.QueryEscape("orders.>.eu"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
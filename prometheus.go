@@ -0,0 +1,171 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// streamMetrics holds the measurements of a single stream
+type streamMetrics struct {
+	subscribers    int64
+	published      int64
+	dropped        int64
+	replayed       int64
+	expired        int64
+	evicted        int64
+	bytesWritten   int64
+	bufferedEvents int64
+}
+
+// PrometheusMetrics is a Metrics implementation that exposes per stream
+// gauges and counters in the prometheus text format. It is an http.Handler
+// to be mounted as a scrape endpoint.
+type PrometheusMetrics struct {
+	namespace string
+	streams   map[string]*streamMetrics
+	mu        sync.Mutex
+}
+
+// NewPrometheusMetrics creates a collector whose metric names are prefixed
+// by namespace, e.g. "sse"
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		namespace: namespace,
+		streams:   make(map[string]*streamMetrics),
+	}
+}
+
+// SubscriberAdded increments the subscribers gauge
+func (p *PrometheusMetrics) SubscriberAdded(streamID string) {
+	p.update(streamID, func(m *streamMetrics) { m.subscribers++ })
+}
+
+// SubscriberRemoved decrements the subscribers gauge
+func (p *PrometheusMetrics) SubscriberRemoved(streamID string) {
+	p.update(streamID, func(m *streamMetrics) { m.subscribers-- })
+}
+
+// EventPublished increments the published events counter
+func (p *PrometheusMetrics) EventPublished(streamID string) {
+	p.update(streamID, func(m *streamMetrics) { m.published++ })
+}
+
+// EventDropped increments the dropped events counter
+func (p *PrometheusMetrics) EventDropped(streamID string) {
+	p.update(streamID, func(m *streamMetrics) { m.dropped++ })
+}
+
+// EventsReplayed adds to the replayed events counter
+func (p *PrometheusMetrics) EventsReplayed(streamID string, n int) {
+	p.update(streamID, func(m *streamMetrics) { m.replayed += int64(n) })
+}
+
+// EventExpired increments the expired events counter
+func (p *PrometheusMetrics) EventExpired(streamID string) {
+	p.update(streamID, func(m *streamMetrics) { m.expired++ })
+}
+
+// EventsEvicted adds to the evicted events counter
+func (p *PrometheusMetrics) EventsEvicted(streamID string, n int) {
+	p.update(streamID, func(m *streamMetrics) { m.evicted += int64(n) })
+}
+
+// BytesWritten adds to the written bytes counter
+func (p *PrometheusMetrics) BytesWritten(streamID string, n int) {
+	p.update(streamID, func(m *streamMetrics) { m.bytesWritten += int64(n) })
+}
+
+// BufferedEvents sets the buffered events gauge
+func (p *PrometheusMetrics) BufferedEvents(streamID string, n int) {
+	p.update(streamID, func(m *streamMetrics) { m.bufferedEvents = int64(n) })
+}
+
+// StreamRemoved drops the metrics of the stream
+func (p *PrometheusMetrics) StreamRemoved(streamID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.streams, streamID)
+}
+
+// ServeHTTP writes all metrics in the prometheus text exposition format
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = p.Write(w)
+}
+
+// Write writes all metrics in the prometheus text exposition format
+func (p *PrometheusMetrics) Write(w io.Writer) error {
+	p.mu.Lock()
+	ids := make([]string, 0, len(p.streams))
+	snapshot := make(map[string]streamMetrics, len(p.streams))
+	for id, m := range p.streams {
+		ids = append(ids, id)
+		snapshot[id] = *m
+	}
+	p.mu.Unlock()
+
+	sort.Strings(ids)
+
+	metrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(m streamMetrics) int64
+	}{
+		{"subscribers", "gauge", "Number of subscribers connected to the stream.", func(m streamMetrics) int64 { return m.subscribers }},
+		{"events_published_total", "counter", "Number of events published to the stream.", func(m streamMetrics) int64 { return m.published }},
+		{"events_dropped_total", "counter", "Number of events not delivered to a subscriber.", func(m streamMetrics) int64 { return m.dropped }},
+		{"events_replayed_total", "counter", "Number of logged events replayed to new subscribers.", func(m streamMetrics) int64 { return m.replayed }},
+		{"events_expired_total", "counter", "Number of events not sent because their TTL passed.", func(m streamMetrics) int64 { return m.expired }},
+		{"events_evicted_total", "counter", "Number of events evicted from the event log.", func(m streamMetrics) int64 { return m.evicted }},
+		{"bytes_written_total", "counter", "Number of bytes of events written to subscribers.", func(m streamMetrics) int64 { return m.bytesWritten }},
+		{"buffered_events", "gauge", "Number of events waiting in the stream's buffer.", func(m streamMetrics) int64 { return m.bufferedEvents }},
+	}
+
+	for _, metric := range metrics {
+		name := metric.name
+		if p.namespace != "" {
+			name = p.namespace + "_" + name
+		}
+
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, metric.help, name, metric.kind); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if _, err := fmt.Fprintf(w, "%s{stream=\"%s\"} %d\n", name, escapeLabel(id), metric.value(snapshot[id])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *PrometheusMetrics) update(streamID string, fn func(m *streamMetrics)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m := p.streams[streamID]
+	if m == nil {
+		m = &streamMetrics{}
+		p.streams[streamID] = m
+	}
+	fn(m)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value for the prometheus text format
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
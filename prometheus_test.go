@@ -0,0 +1,152 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMetricsWrite(t *testing.T) {
+	p := NewPrometheusMetrics("sse")
+
+	p.SubscriberAdded("b")
+	p.SubscriberAdded("a")
+	p.SubscriberAdded("a")
+	p.SubscriberRemoved("a")
+	p.EventPublished("a")
+	p.EventsReplayed("a", 3)
+	p.BytesWritten("a", 42)
+	p.BufferedEvents("a", 5)
+	p.EventDropped(`quo"te`)
+
+	var buf bytes.Buffer
+	require.Nil(t, p.Write(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE sse_subscribers gauge\n")
+	assert.Contains(t, out, "# TYPE sse_events_published_total counter\n")
+	assert.Contains(t, out, "sse_subscribers{stream=\"a\"} 1\nsse_subscribers{stream=\"b\"} 1\n")
+	assert.Contains(t, out, "sse_events_published_total{stream=\"a\"} 1\n")
+	assert.Contains(t, out, "sse_events_replayed_total{stream=\"a\"} 3\n")
+	assert.Contains(t, out, "sse_bytes_written_total{stream=\"a\"} 42\n")
+	assert.Contains(t, out, "sse_buffered_events{stream=\"a\"} 5\n")
+	assert.Contains(t, out, "sse_events_dropped_total{stream=\"quo\\\"te\"} 1\n")
+}
+
+func TestPrometheusMetricsServeHTTP(t *testing.T) {
+	p := NewPrometheusMetrics("")
+	p.EventPublished("test")
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, rec.Body.String(), "events_published_total{stream=\"test\"} 1\n")
+}
+
+func TestServerMetrics(t *testing.T) {
+	p := NewPrometheusMetrics("sse")
+
+	s := New()
+	s.Metrics = p
+	s.EventLogLimits = EventLogLimits{MaxEvents: 1}
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+	s.Publish("test", &Event{Data: []byte("test 1")})
+	s.Publish("test", &Event{Data: []byte("test 2")})
+
+	c := NewClient(server.URL)
+	events := make(chan *Event)
+	require.Nil(t, c.SubscribeChan("test", events))
+	defer c.Unsubscribe(events)
+
+	msg, err := waitEvent(events, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test 2"), msg.Data)
+
+	var buf bytes.Buffer
+	require.Nil(t, p.Write(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "sse_subscribers{stream=\"test\"} 1\n")
+	assert.Contains(t, out, "sse_events_published_total{stream=\"test\"} 2\n")
+	assert.Contains(t, out, "sse_events_replayed_total{stream=\"test\"} 1\n")
+	assert.Contains(t, out, "sse_events_evicted_total{stream=\"test\"} 1\n")
+	assert.NotContains(t, out, "sse_bytes_written_total{stream=\"test\"} 0\n")
+}
+
+func TestPrometheusMetricsStreamRemoved(t *testing.T) {
+	p := NewPrometheusMetrics("sse")
+
+	s := New()
+	s.Metrics = p
+	defer s.Close()
+
+	s.CreateStream("test")
+	s.CreateStream("other")
+	s.Publish("test", &Event{Data: []byte("test")})
+	s.Publish("other", &Event{Data: []byte("test")})
+
+	s.RemoveStream("test")
+
+	require.Eventually(t, func() bool {
+		var buf bytes.Buffer
+		require.Nil(t, p.Write(&buf))
+		return !strings.Contains(buf.String(), "stream=\"test\"")
+	}, time.Second, time.Millisecond*10)
+
+	var buf bytes.Buffer
+	require.Nil(t, p.Write(&buf))
+	assert.Contains(t, buf.String(), "sse_events_published_total{stream=\"other\"} 1\n")
+}
+
+func TestPrometheusMetricsAfterStreamRemoved(t *testing.T) {
+	p := NewPrometheusMetrics("sse")
+
+	s := New()
+	s.Metrics = p
+	defer s.Close()
+
+	s.CreateStream("test")
+	str := s.getStream("test")
+	s.Publish("test", &Event{Data: []byte("test")})
+
+	s.RemoveStream("test")
+
+	require.Eventually(t, func() bool {
+		var buf bytes.Buffer
+		require.Nil(t, p.Write(&buf))
+		return !strings.Contains(buf.String(), "stream=\"test\"")
+	}, time.Second, time.Millisecond*10)
+
+	// Late measurements of the removed stream, e.g. a racing TryPublish
+	str.metrics.EventDropped("test")
+	str.metrics.BytesWritten("test", 42)
+
+	var buf bytes.Buffer
+	require.Nil(t, p.Write(&buf))
+	assert.NotContains(t, buf.String(), "stream=\"test\"")
+
+	// A stream created again with the same id is measured
+	s.CreateStream("test")
+	s.Publish("test", &Event{Data: []byte("test")})
+
+	require.Eventually(t, func() bool {
+		var buf bytes.Buffer
+		require.Nil(t, p.Write(&buf))
+		return strings.Contains(buf.String(), "sse_events_published_total{stream=\"test\"} 1\n")
+	}, time.Second, time.Millisecond*10)
+}
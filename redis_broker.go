@@ -0,0 +1,221 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+// DefaultRedisPrefix is prepended to the redis keys and channels used by a
+// RedisBroker
+const DefaultRedisPrefix = "sse:"
+
+// redisStreamID matches the ids of redis stream entries
+var redisStreamID = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+// RedisClient is the subset of redis commands used by RedisBroker. It can be
+// implemented on top of any redis library, the goredis module provides an
+// implementation for go-redis.
+type RedisClient interface {
+	// Publish posts a message on a pub/sub channel (PUBLISH)
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Subscribe calls handler with every message posted on a pub/sub channel
+	// until ctx is canceled (SUBSCRIBE). It returns once subscribed.
+	Subscribe(ctx context.Context, channel string, handler func(message []byte)) error
+	// XAdd appends a message to a stream, trimming it to approximately maxLen
+	// entries when maxLen is positive, and returns the id of the new entry
+	// (XADD key [MAXLEN ~ maxLen] * message <message>)
+	XAdd(ctx context.Context, stream string, maxLen int64, message []byte) (string, error)
+	// XRange returns the entries of a stream with an id greater or equal to
+	// start (XRANGE key start +)
+	XRange(ctx context.Context, stream, start string) ([]RedisStreamEntry, error)
+	// XTrim removes the entries of a stream with an id lower than minID
+	// (XTRIM key MINID minID)
+	XTrim(ctx context.Context, stream, minID string) error
+}
+
+// RedisStreamEntry is an entry of a redis stream
+type RedisStreamEntry struct {
+	ID      string
+	Message []byte
+}
+
+// RedisBroker is a Broker that fans events out with redis pub/sub and keeps
+// a shared event log of each stream in a redis stream. Events published
+// without an id, e.g. when the server has no IDGenerator, are given the id
+// of their redis stream entry. Other ids are stored with the event, replays
+// from them scan the redis stream.
+type RedisBroker struct {
+	// Prefix of the redis keys and channels, defaults to DefaultRedisPrefix
+	Prefix string
+	// Approximate maximum number of events kept in each redis stream,
+	// unbounded when zero
+	MaxLen int64
+
+	client RedisClient
+}
+
+// NewRedisBroker creates a broker using the given redis client
+func NewRedisBroker(client RedisClient) *RedisBroker {
+	return &RedisBroker{
+		Prefix: DefaultRedisPrefix,
+		client: client,
+	}
+}
+
+// Publish logs the event in the stream's redis stream, then posts it to
+// every instance
+func (b *RedisBroker) Publish(streamID string, ev *Event) error {
+	ctx := context.Background()
+
+	if ev.hasContent() {
+		if err := b.append(ctx, streamID, ev); err != nil {
+			return err
+		}
+	}
+
+	message, err := json.Marshal(toStoredEvent(ev))
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, b.channel(streamID), message)
+}
+
+// Subscribe calls handler with the events posted to the stream
+func (b *RedisBroker) Subscribe(streamID string, handler func(ev *Event)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := b.client.Subscribe(ctx, b.channel(streamID), func(message []byte) {
+		var se storedEvent
+		if err := json.Unmarshal(message, &se); err != nil {
+			return
+		}
+		handler(se.toEvent())
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return cancel, nil
+}
+
+// EventStore returns the stream's event log kept in redis
+func (b *RedisBroker) EventStore(streamID string) EventStore {
+	return &redisEventStore{broker: b, streamID: streamID}
+}
+
+// append stores an event in the stream's redis stream, assigning it the id
+// of its entry if it has none
+func (b *RedisBroker) append(ctx context.Context, streamID string, ev *Event) error {
+	ev.timestamp = time.Now()
+
+	message, err := json.Marshal(toStoredEvent(ev))
+	if err != nil {
+		return err
+	}
+
+	id, err := b.client.XAdd(ctx, b.key(streamID), b.MaxLen, message)
+	if err != nil {
+		return err
+	}
+
+	if len(ev.ID) == 0 {
+		ev.ID = []byte(id)
+	}
+
+	return nil
+}
+
+func (b *RedisBroker) key(streamID string) string {
+	return b.Prefix + "log:" + streamID
+}
+
+func (b *RedisBroker) channel(streamID string) string {
+	return b.Prefix + "events:" + streamID
+}
+
+// redisEventStore is the EventStore view of a stream's redis stream
+type redisEventStore struct {
+	broker   *RedisBroker
+	streamID string
+}
+
+func (s *redisEventStore) Append(ev *Event) error {
+	if !ev.hasContent() {
+		return nil
+	}
+	return s.broker.append(context.Background(), s.streamID, ev)
+}
+
+func (s *redisEventStore) ReplayFrom(id string) ([]*Event, error) {
+	_, events, err := s.find(context.Background(), id)
+	return events, err
+}
+
+func (s *redisEventStore) Trim(id string) error {
+	ctx := context.Background()
+
+	entries, events, err := s.find(ctx, id)
+	if err != nil || len(events) == 0 || string(events[0].ID) != id {
+		return err
+	}
+
+	return s.broker.client.XTrim(ctx, s.broker.key(s.streamID), entries[0].ID)
+}
+
+// find returns the entries and events from the event with the given id. All
+// of them are returned if the id is not found. Ids of redis stream entries
+// are looked up directly, other ids by scanning the stream.
+func (s *redisEventStore) find(ctx context.Context, id string) ([]RedisStreamEntry, []*Event, error) {
+	if redisStreamID.MatchString(id) {
+		entries, events, err := s.xrange(ctx, id)
+		if err != nil || len(events) > 0 && string(events[0].ID) == id {
+			return entries, events, err
+		}
+	}
+
+	entries, events, err := s.xrange(ctx, "-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if i := indexOf(events, id); i > 0 {
+		return entries[i:], events[i:], nil
+	}
+
+	return entries, events, nil
+}
+
+// xrange reads the entries of the stream from start
+func (s *redisEventStore) xrange(ctx context.Context, start string) ([]RedisStreamEntry, []*Event, error) {
+	entries, err := s.broker.client.XRange(ctx, s.broker.key(s.streamID), start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make([]*Event, 0, len(entries))
+	for i := range entries {
+		var se storedEvent
+		if err := json.Unmarshal(entries[i].Message, &se); err != nil {
+			return nil, nil, err
+		}
+		ev := se.toEvent()
+		if len(ev.ID) == 0 {
+			ev.ID = []byte(entries[i].ID)
+		}
+		events = append(events, ev)
+	}
+
+	return entries, events, nil
+}
+
+func (s *redisEventStore) Close() error {
+	return nil
+}
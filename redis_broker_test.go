@@ -0,0 +1,249 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is an in-memory stand-in for a redis server implementing the
+// commands used by RedisBroker
+type fakeRedis struct {
+	subscribers map[string][]chan []byte
+	streams     map[string][]RedisStreamEntry
+	lastID      int
+	mu          sync.Mutex
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		subscribers: make(map[string][]chan []byte),
+		streams:     make(map[string][]RedisStreamEntry),
+	}
+}
+
+func (r *fakeRedis) Publish(ctx context.Context, channel string, message []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ch := range r.subscribers[channel] {
+		ch <- message
+	}
+	return nil
+}
+
+func (r *fakeRedis) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	ch := make(chan []byte, 1024)
+
+	r.mu.Lock()
+	r.subscribers[channel] = append(r.subscribers[channel], ch)
+	r.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case message := <-ch:
+				handler(message)
+			case <-ctx.Done():
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				for i := range r.subscribers[channel] {
+					if r.subscribers[channel][i] == ch {
+						r.subscribers[channel] = append(r.subscribers[channel][:i], r.subscribers[channel][i+1:]...)
+						break
+					}
+				}
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *fakeRedis) XAdd(ctx context.Context, stream string, maxLen int64, message []byte) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	id := "1000-" + strconv.Itoa(r.lastID)
+	r.streams[stream] = append(r.streams[stream], RedisStreamEntry{ID: id, Message: message})
+	if maxLen > 0 && int64(len(r.streams[stream])) > maxLen {
+		r.streams[stream] = r.streams[stream][int64(len(r.streams[stream]))-maxLen:]
+	}
+	return id, nil
+}
+
+func (r *fakeRedis) XRange(ctx context.Context, stream, start string) ([]RedisStreamEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []RedisStreamEntry
+	for _, entry := range r.streams[stream] {
+		if start == "-" || compareRedisIDs(entry.ID, start) >= 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeRedis) XTrim(ctx context.Context, stream, minID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []RedisStreamEntry
+	for _, entry := range r.streams[stream] {
+		if compareRedisIDs(entry.ID, minID) >= 0 {
+			entries = append(entries, entry)
+		}
+	}
+	r.streams[stream] = entries
+	return nil
+}
+
+func compareRedisIDs(a, b string) int {
+	pa := strings.SplitN(a, "-", 2)
+	pb := strings.SplitN(b, "-", 2)
+	for i := 0; i < 2; i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+func TestRedisBrokerFanOut(t *testing.T) {
+	redis := newFakeRedis()
+
+	s1 := New()
+	s1.Broker = NewRedisBroker(redis)
+	defer s1.Close()
+
+	s2 := New()
+	s2.Broker = NewRedisBroker(redis)
+	defer s2.Close()
+
+	s1.CreateStream("test")
+	s2.CreateStream("test")
+
+	sub := s2.getStream("test").addSubscriber("", nil)
+
+	s1.Publish("test", &Event{Data: []byte("test")})
+
+	ev, err := waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), ev.Data)
+	assert.Equal(t, []byte("1000-1"), ev.ID)
+	assert.False(t, ev.timestamp.IsZero())
+}
+
+func TestRedisBrokerSharedReplay(t *testing.T) {
+	redis := newFakeRedis()
+
+	s1 := New()
+	s1.Broker = NewRedisBroker(redis)
+	defer s1.Close()
+
+	for i := 1; i <= 3; i++ {
+		s1.Publish("test", &Event{Data: []byte("test " + strconv.Itoa(i))})
+	}
+
+	// A second instance replays events it never received
+	s2 := New()
+	s2.Broker = NewRedisBroker(redis)
+	defer s2.Close()
+
+	s2.CreateStream("test")
+	sub := s2.getStream("test").addSubscriber("1000-2", nil)
+
+	ev, err := waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("1000-2"), ev.ID)
+	assert.Equal(t, []byte("test 2"), ev.Data)
+
+	ev, err = waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test 3"), ev.Data)
+}
+
+func TestRedisEventStore(t *testing.T) {
+	redis := newFakeRedis()
+	store := NewRedisBroker(redis).EventStore("test")
+
+	for i := 1; i <= 3; i++ {
+		require.Nil(t, store.Append(&Event{Data: []byte("test " + strconv.Itoa(i))}))
+	}
+
+	events, err := store.ReplayFrom("not-a-redis-id")
+	require.Nil(t, err)
+	assert.Len(t, events, 3)
+
+	require.Nil(t, store.Trim("1000-3"))
+
+	events, err = store.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, []byte("test 3"), events[0].Data)
+	assert.Nil(t, store.Close())
+}
+
+func TestRedisBrokerIDGenerator(t *testing.T) {
+	redis := newFakeRedis()
+
+	s1 := New()
+	s1.Broker = NewRedisBroker(redis)
+	s1.IDGenerator = PassthroughIDGenerator
+	defer s1.Close()
+
+	s1.CreateStream("test")
+	sub := s1.getStream("test").addSubscriber("", nil)
+
+	for i := 41; i <= 43; i++ {
+		s1.Publish("test", &Event{ID: []byte(strconv.Itoa(i)), Data: []byte("test")})
+	}
+
+	ev, err := waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("41"), ev.ID)
+
+	// Replays start from publisher ids, even when they look like entry ids
+	s2 := New()
+	s2.Broker = NewRedisBroker(redis)
+	defer s2.Close()
+
+	s2.CreateStream("test")
+	sub = s2.getStream("test").addSubscriber("42", nil)
+
+	ev, err = waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("42"), ev.ID)
+
+	ev, err = waitEvent(sub.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("43"), ev.ID)
+
+	store := s2.Broker.EventStore("test")
+	require.Nil(t, store.Trim("43"))
+
+	events, err := store.ReplayFrom("")
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, []byte("43"), events[0].ID)
+}
@@ -0,0 +1,77 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultStreamParam is the query parameter holding the stream id by default
+const DefaultStreamParam = "stream"
+
+// StreamIDResolver returns the id of the stream a request subscribes to. An
+// empty id means no stream was specified.
+type StreamIDResolver func(r *http.Request) (string, error)
+
+// StreamURLBuilder returns the url a client connects to in order to
+// subscribe to a stream
+type StreamURLBuilder func(baseURL, stream string) (string, error)
+
+// QueryStreamIDResolver reads the stream id from the given query parameter
+func QueryStreamIDResolver(name string) StreamIDResolver {
+	return func(r *http.Request) (string, error) {
+		return r.URL.Query().Get(name), nil
+	}
+}
+
+// PathStreamIDResolver reads the stream id from the part of the path that
+// follows prefix, e.g. "/streams/" for handlers mounted at "/streams/{id}"
+func PathStreamIDResolver(prefix string) StreamIDResolver {
+	return func(r *http.Request) (string, error) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return "", fmt.Errorf("path %s does not start with %s", r.URL.Path, prefix)
+		}
+		return strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), nil
+	}
+}
+
+// HeaderStreamIDResolver reads the stream id from the given request header
+func HeaderStreamIDResolver(name string) StreamIDResolver {
+	return func(r *http.Request) (string, error) {
+		return r.Header.Get(name), nil
+	}
+}
+
+// QueryStreamURLBuilder adds the stream id as the given query parameter
+func QueryStreamURLBuilder(name string) StreamURLBuilder {
+	return func(baseURL, stream string) (string, error) {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return "", err
+		}
+
+		query := u.Query()
+		query.Add(name, stream)
+		u.RawQuery = query.Encode()
+
+		return u.String(), nil
+	}
+}
+
+// PathStreamURLBuilder appends the stream id to the path of the url
+func PathStreamURLBuilder(baseURL, stream string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + stream
+	u.RawPath = ""
+
+	return u.String(), nil
+}
@@ -0,0 +1,87 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryStreamIDResolver(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events?topic=test", nil)
+
+	id, err := QueryStreamIDResolver("topic")(r)
+	require.Nil(t, err)
+	assert.Equal(t, "test", id)
+}
+
+func TestPathStreamIDResolver(t *testing.T) {
+	resolve := PathStreamIDResolver("/streams/")
+
+	id, err := resolve(httptest.NewRequest("GET", "/streams/orders.eu", nil))
+	require.Nil(t, err)
+	assert.Equal(t, "orders.eu", id)
+
+	id, err = resolve(httptest.NewRequest("GET", "/streams/", nil))
+	require.Nil(t, err)
+	assert.Equal(t, "", id)
+
+	_, err = resolve(httptest.NewRequest("GET", "/events/test", nil))
+	assert.NotNil(t, err)
+}
+
+func TestHeaderStreamIDResolver(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("X-Stream", "test")
+
+	id, err := HeaderStreamIDResolver("X-Stream")(r)
+	require.Nil(t, err)
+	assert.Equal(t, "test", id)
+}
+
+func TestQueryStreamURLBuilder(t *testing.T) {
+	u, err := QueryStreamURLBuilder("topic")("http://server/events?search=example", "test")
+	require.Nil(t, err)
+	assert.Equal(t, "http://server/events?search=example&topic=test", u)
+}
+
+func TestPathStreamURLBuilder(t *testing.T) {
+	u, err := PathStreamURLBuilder("http://server/streams/?search=example", "test")
+	require.Nil(t, err)
+	assert.Equal(t, "http://server/streams/test?search=example", u)
+}
+
+func TestPathStreamRouting(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	s.StreamIDResolver = PathStreamIDResolver("/streams/")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/streams/", s.ServeHTTP)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	c := NewClient(server.URL + "/streams")
+	c.StreamURLBuilder = PathStreamURLBuilder
+
+	events := make(chan *Event)
+	err := c.SubscribeChan("test", events)
+	require.Nil(t, err)
+	defer c.Unsubscribe(events)
+
+	s.Publish("test", &Event{Data: []byte("test")})
+
+	msg, err := wait(events, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test"), msg)
+}
@@ -0,0 +1,92 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"strconv"
+	"sync"
+)
+
+// GapCallback is called when the numeric id of an event does not follow the
+// id of the previous one, e.g. when events were missed while reconnecting
+type GapCallback func(lastID, nextID uint64)
+
+// ClientDeduplicate drops the events whose id is one of the last window ids
+// received, such as the last event replayed again after a reconnect
+func ClientDeduplicate(window int) func(c *Client) {
+	return func(c *Client) {
+		c.sequence.window = window
+	}
+}
+
+// OnGap specifies the function to run when the ids of consecutive events are
+// not contiguous integers, so consumers can resynchronize. It expects the
+// server to use sequential ids, events with other ids are not checked.
+func (c *Client) OnGap(fn GapCallback) {
+	c.sequence.mu.Lock()
+	defer c.sequence.mu.Unlock()
+
+	c.sequence.gapcb = fn
+}
+
+// eventSequence keeps track of the ids of the events received
+type eventSequence struct {
+	// number of ids remembered to drop duplicates
+	window int
+	seen   map[string]struct{}
+	// remembered ids, in the order they were received
+	ids  []string
+	next int
+
+	gapcb   GapCallback
+	last    uint64
+	hasLast bool
+
+	mu sync.Mutex
+}
+
+// receive records the id of an event. It returns false if the event is a
+// duplicate that must be dropped.
+func (s *eventSequence) receive(id []byte) bool {
+	s.mu.Lock()
+
+	if s.window > 0 {
+		if _, ok := s.seen[string(id)]; ok {
+			s.mu.Unlock()
+			return false
+		}
+		s.remember(string(id))
+	}
+
+	gapcb := s.gapcb
+	last, hasLast := s.last, s.hasLast
+
+	n, err := strconv.ParseUint(string(id), 10, 64)
+	s.last, s.hasLast = n, err == nil
+
+	s.mu.Unlock()
+
+	if gapcb != nil && hasLast && err == nil && n > last+1 {
+		gapcb(last, n)
+	}
+
+	return true
+}
+
+// remember adds an id to the window, forgetting the oldest one once full
+func (s *eventSequence) remember(id string) {
+	if s.seen == nil {
+		s.seen = make(map[string]struct{}, s.window)
+	}
+
+	if len(s.ids) < s.window {
+		s.ids = append(s.ids, id)
+	} else {
+		delete(s.seen, s.ids[s.next])
+		s.ids[s.next] = id
+		s.next = (s.next + 1) % s.window
+	}
+	s.seen[id] = struct{}{}
+}
@@ -0,0 +1,87 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+func TestEventSequenceDeduplicate(t *testing.T) {
+	s := eventSequence{window: 2}
+
+	assert.True(t, s.receive([]byte("a")))
+	assert.True(t, s.receive([]byte("b")))
+	assert.False(t, s.receive([]byte("a")))
+	assert.False(t, s.receive([]byte("b")))
+
+	// Only the last ids of the window are remembered
+	assert.True(t, s.receive([]byte("c")))
+	assert.True(t, s.receive([]byte("a")))
+	assert.False(t, s.receive([]byte("c")))
+}
+
+func TestEventSequenceGaps(t *testing.T) {
+	var gaps [][2]uint64
+	s := eventSequence{gapcb: func(last, next uint64) {
+		gaps = append(gaps, [2]uint64{last, next})
+	}}
+
+	for _, id := range []string{"1", "2", "4", "5", "x", "9", "10", "3", "7"} {
+		assert.True(t, s.receive([]byte(id)))
+	}
+
+	// Non numeric ids and lower ids reset the sequence
+	assert.Equal(t, [][2]uint64{{2, 4}, {3, 7}}, gaps)
+}
+
+func TestClientDeduplicateAcrossReconnects(t *testing.T) {
+	// The server replays from the last event id, inclusive, and misses one.
+	// The first connection stalls until the client reconnects.
+	var connections int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&connections, 1) == 1 {
+			fmt.Fprint(w, "id: 1\ndata: a\n\nid: 2\ndata: b\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintf(w, "id: %s\ndata: b\n\nid: 3\ndata: c\n\nid: 5\ndata: e\n\n", r.Header.Get("Last-Event-ID"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient(server.URL+"/events", ClientDeduplicate(16), ClientHeartbeatTimeout(time.Millisecond*100))
+	c.ReconnectStrategy = backoff.WithMaxTries(backoff.NewConstantBackOff(time.Millisecond*10), 1)
+
+	gaps := make(chan [2]uint64, 1)
+	c.OnGap(func(last, next uint64) {
+		gaps <- [2]uint64{last, next}
+	})
+
+	var ids []string
+	err := c.SubscribeRaw(func(msg *Event) {
+		ids = append(ids, string(msg.ID))
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{"1", "2", "3", "5"}, ids)
+	select {
+	case gap := <-gaps:
+		assert.Equal(t, [2]uint64{3, 5}, gap)
+	default:
+		t.Fatal("expected a gap between ids 3 and 5")
+	}
+}
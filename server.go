@@ -5,9 +5,12 @@
 package sse
 
 import (
+	"context"
 	"encoding/base64"
 	"sync"
 	"time"
+
+	"gopkg.in/cenkalti/backoff.v1"
 )
 
 // DefaultBufferSize size of the queue that holds the streams messages.
@@ -25,17 +28,63 @@ type Server struct {
 	EncodeBase64 bool
 	// Splits an events data into multiple data: entries
 	SplitData bool
+	// Specifies what happens when a subscriber's buffer is full, blocks by default
+	SlowSubscriberPolicy SlowSubscriberPolicy
+	// Number of events queued for each subscriber, defaults to DefaultSubscriberBufferSize
+	SubscriberBufferSize int
+	// Interval between keepalive comments sent to each subscriber, disabled when zero
+	HeartbeatInterval time.Duration
+	// Resolves the stream a request subscribes to, defaults to the stream query parameter
+	StreamIDResolver StreamIDResolver
+	// Authorizes a request before it subscribes to a stream
+	Authorize AuthorizeFunc
+	// Interval at which the authorization of connected subscribers is
+	// re-evaluated, subscribers that are no longer authorized are disconnected
+	AuthorizeInterval time.Duration
 	// Enables creation of a stream when a client connects
 	AutoStream bool
 	// Enables automatic replay for each new subscriber that connects
 	AutoReplay bool
+	// Creates the event store used by a new stream, defaults to an in-memory EventLog
+	EventStoreFactory func(streamID string) EventStore
+	// Bounds the default in-memory event log of each stream. MaxAge defaults to EventTTL
+	EventLogLimits EventLogLimits
+	// Assigns ids to published events, defaults to sequential ids
+	IDGenerator IDGenerator
+
+	// Distributes published events to every server instance, defaults to
+	// delivering events in-process only
+	Broker Broker
+	// Receives measurements of the streams and subscribers
+	Metrics Metrics
+	// Decides whether an event is sent to a subscriber, in addition to the
+	// event types requested by the subscriber
+	Filter FilterFunc
+	// Starts spans when events are published and delivered
+	Tracer Tracer
+	// Sends the trace context of events to clients in a trace: field
+	PropagateTraceContext bool
+	// Final event sent to every subscriber by Shutdown, e.g. a retry: hint
+	ShutdownEvent *Event
 
 	// Specifies the function to run when client subscribe or un-subscribe
 	OnSubscribe   func(streamID string, sub *Subscriber)
 	OnUnsubscribe func(streamID string, sub *Subscriber)
+	// Specifies the function to run when the slow subscriber policy fires
+	OnSlowSubscriber func(streamID string, sub *Subscriber, policy SlowSubscriberPolicy)
+	// Specifies the function to run when the event store or the broker
+	// subscription of a stream fails
+	OnError func(streamID string, err error)
 
 	streams   map[string]*Stream
+	patterns  map[*patternSubscription]struct{}
 	muStreams sync.RWMutex
+
+	handlers     sync.WaitGroup
+	shuttingDown bool
+	quit         chan struct{}
+	quitOnce     sync.Once
+	muHandlers   sync.Mutex
 }
 
 // New will create a server and setup defaults
@@ -64,6 +113,8 @@ func NewWithCallback(onSubscribe, onUnsubscribe func(streamID string, sub *Subsc
 
 // Close shuts down the server, closes all of the streams and connections
 func (s *Server) Close() {
+	s.stop()
+
 	s.muStreams.Lock()
 	defer s.muStreams.Unlock()
 
@@ -73,21 +124,138 @@ func (s *Server) Close() {
 	}
 }
 
+// Shutdown gracefully shuts down the server. It stops accepting new
+// subscribers, publishes the events buffered by each stream, sends the
+// ShutdownEvent to every subscriber and waits for all connections to be
+// served. If the context expires first, the streams are closed and the
+// context's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.muHandlers.Lock()
+	s.shuttingDown = true
+	s.muHandlers.Unlock()
+
+	s.stop()
+
+	s.muStreams.Lock()
+	streams := make([]*Stream, 0, len(s.streams))
+	for id := range s.streams {
+		streams = append(streams, s.streams[id])
+		delete(s.streams, id)
+	}
+	s.muStreams.Unlock()
+
+	for _, str := range streams {
+		str.gracefulClose(ctx, s.ShutdownEvent)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// Stop the streams still delivering to stalled subscribers
+		for _, str := range streams {
+			str.close()
+		}
+		return ctx.Err()
+	}
+}
+
 // CreateStream will create a new stream and register it
 func (s *Server) CreateStream(id string) *Stream {
+	str, patterns, created := s.createStream(id)
+
+	if created && s.Broker != nil {
+		s.subscribeBroker(str)
+	}
+
+	// Attach the connections subscribed to a matching pattern
+	for _, ps := range patterns {
+		ps.attach(str)
+	}
+
+	return str
+}
+
+// createStream creates and registers a stream. When it is new, the pattern
+// subscriptions matching it are returned.
+func (s *Server) createStream(id string) (*Stream, []*patternSubscription, bool) {
 	s.muStreams.Lock()
 	defer s.muStreams.Unlock()
 
 	if s.streams[id] != nil {
-		return s.streams[id]
+		return s.streams[id], nil, false
 	}
 
 	str := newStream(id, s.BufferSize, s.AutoReplay, s.AutoStream, s.OnSubscribe, s.OnUnsubscribe)
+	if s.EventStoreFactory != nil {
+		str.Eventlog = s.EventStoreFactory(id)
+	} else {
+		str.Eventlog = NewBoundedEventLog(s.eventLogLimits())
+	}
+	if s.Broker != nil {
+		if store := s.Broker.EventStore(id); store != nil {
+			str.Eventlog = store
+			str.sharedLog = true
+		}
+	}
+	str.metrics = newRemovableMetrics(s.metrics())
+	if log, ok := str.Eventlog.(*EventLog); ok {
+		log.onEvict = func(n int) {
+			str.metrics.EventsEvicted(id, n)
+		}
+	}
+	str.IDGenerator = s.IDGenerator
+	str.HeartbeatInterval = s.HeartbeatInterval
+	str.SlowSubscriberPolicy = s.SlowSubscriberPolicy
+	str.SubscriberBufferSize = s.SubscriberBufferSize
+	str.OnSlowSubscriber = s.OnSlowSubscriber
+	str.OnError = s.OnError
+	str.Filter = s.Filter
 	str.run()
 
 	s.streams[id] = str
 
-	return str
+	return str, s.matchingPatterns(id), true
+}
+
+// subscribeBroker subscribes a stream to the events published by every
+// instance. Failures are passed to OnError, and the subscription is retried
+// in the background until it succeeds or the stream is closed.
+func (s *Server) subscribeBroker(str *Stream) {
+	unsubscribe, err := s.Broker.Subscribe(str.ID, str.receive)
+	if err == nil {
+		str.setUnsubscribe(unsubscribe)
+		return
+	}
+	str.reportError(err)
+
+	go func() {
+		retry := backoff.NewExponentialBackOff()
+		retry.MaxElapsedTime = 0
+
+		for {
+			timer := time.NewTimer(retry.NextBackOff())
+			select {
+			case <-timer.C:
+			case <-str.quit:
+				timer.Stop()
+				return
+			}
+
+			unsubscribe, err := s.Broker.Subscribe(str.ID, str.receive)
+			if err == nil {
+				str.setUnsubscribe(unsubscribe)
+				return
+			}
+			str.reportError(err)
+		}
+	}()
 }
 
 // RemoveStream will remove a stream
@@ -110,7 +278,23 @@ func (s *Server) StreamExists(id string) bool {
 // If the stream's buffer is full, it blocks until the message is sent out to
 // all subscribers (but not necessarily arrived the clients), or when the
 // stream is closed.
+//
+// When a Broker is set, the event is handed to the broker, which delivers it
+// to the stream on every server instance.
 func (s *Server) Publish(id string, event *Event) {
+	s.PublishWithContext(context.Background(), id, event)
+}
+
+// PublishWithContext is the same as Publish, the publish span started by the
+// Tracer is a child of the span held by ctx.
+func (s *Server) PublishWithContext(ctx context.Context, id string, event *Event) {
+	defer s.tracePublish(ctx, id, event).End()
+
+	if s.Broker != nil {
+		_ = s.brokerPublish(id, s.process(event))
+		return
+	}
+
 	stream := s.getStream(id)
 	if stream == nil {
 		return
@@ -126,7 +310,16 @@ func (s *Server) Publish(id string, event *Event) {
 // the call to be blocked, it simply drops the message and returns false.
 // Together with a small BufferSize, it can be useful when publishing the
 // latest message ASAP is more important than reliable delivery.
+//
+// When a Broker is set, it returns false if the broker failed to publish the
+// event.
 func (s *Server) TryPublish(id string, event *Event) bool {
+	defer s.tracePublish(context.Background(), id, event).End()
+
+	if s.Broker != nil {
+		return s.brokerPublish(id, s.process(event)) == nil
+	}
+
 	stream := s.getStream(id)
 	if stream == nil {
 		return false
@@ -136,8 +329,64 @@ func (s *Server) TryPublish(id string, event *Event) bool {
 	case stream.event <- s.process(event):
 		return true
 	default:
+		stream.metrics.EventDropped(id)
+		return false
+	}
+}
+
+// brokerPublish hands an event to the broker. Streams do not assign the ids
+// of events stored in a shared log, so the IDGenerator is applied here, and
+// the broker assigns an id when it is empty.
+func (s *Server) brokerPublish(id string, event *Event) error {
+	if s.Broker.EventStore(id) != nil {
+		if s.IDGenerator != nil && event.hasContent() {
+			event.ID = s.IDGenerator(event)
+		} else if s.AutoReplay {
+			event.ID = nil
+		}
+	}
+
+	return s.Broker.Publish(id, event)
+}
+
+// quitChan returns a channel closed once the server is closed or shut down
+func (s *Server) quitChan() chan struct{} {
+	s.muHandlers.Lock()
+	defer s.muHandlers.Unlock()
+
+	if s.quit == nil {
+		s.quit = make(chan struct{})
+	}
+	return s.quit
+}
+
+// stop closes the quit channel
+func (s *Server) stop() {
+	quit := s.quitChan()
+	s.quitOnce.Do(func() {
+		close(quit)
+	})
+}
+
+// startHandler registers a connection being served, it returns false when
+// the server is shutting down
+func (s *Server) startHandler() bool {
+	s.muHandlers.Lock()
+	defer s.muHandlers.Unlock()
+
+	if s.shuttingDown {
 		return false
 	}
+
+	s.handlers.Add(1)
+	return true
+}
+
+func (s *Server) metrics() Metrics {
+	if s.Metrics != nil {
+		return s.Metrics
+	}
+	return nopMetrics{}
 }
 
 func (s *Server) getStream(id string) *Stream {
@@ -146,6 +395,14 @@ func (s *Server) getStream(id string) *Stream {
 	return s.streams[id]
 }
 
+func (s *Server) eventLogLimits() EventLogLimits {
+	limits := s.EventLogLimits
+	if limits.MaxAge == 0 {
+		limits.MaxAge = s.EventTTL
+	}
+	return limits
+}
+
 func (s *Server) process(event *Event) *Event {
 	if s.EncodeBase64 {
 		output := make([]byte, base64.StdEncoding.EncodedLen(len(event.Data)))
@@ -5,8 +5,13 @@
 package sse
 
 import (
+	"context"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -94,7 +99,7 @@ func TestServerExistingStreamPublish(t *testing.T) {
 
 	s.CreateStream("test")
 	stream := s.getStream("test")
-	sub := stream.addSubscriber(0, nil)
+	sub := stream.addSubscriber("", nil)
 
 	s.Publish("test", &Event{Data: []byte("test")})
 
@@ -111,3 +116,125 @@ func TestServerNonExistentStreamPublish(t *testing.T) {
 
 	assert.NotPanics(t, func() { s.Publish("test", &Event{Data: []byte("test")}) })
 }
+
+func TestServerEventLogLimits(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	s.EventTTL = time.Minute
+	s.EventLogLimits = EventLogLimits{MaxEvents: 2}
+
+	stream := s.CreateStream("test")
+
+	log, ok := stream.Eventlog.(*EventLog)
+	require.True(t, ok)
+	assert.Equal(t, EventLogLimits{MaxEvents: 2, MaxAge: time.Minute}, log.limits)
+
+	for i := 0; i < 5; i++ {
+		s.Publish("test", &Event{Data: []byte("test")})
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	assert.Equal(t, 2, log.Len())
+
+	stream.SetEventLogLimits(EventLogLimits{MaxEvents: 1})
+	assert.Equal(t, 1, log.Len())
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := New()
+	s.ShutdownEvent = &Event{Retry: []byte("5000"), Comment: []byte("shutting down")}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.ServeHTTP)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	resp, err := http.Get(server.URL + "/events?stream=test")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	time.Sleep(time.Millisecond * 50)
+
+	for i := 0; i < 3; i++ {
+		s.Publish("test", &Event{Data: []byte("test")})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, s.Shutdown(ctx))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(body), "data: test\n"))
+	assert.True(t, strings.HasSuffix(string(body), "retry: 5000\n: shutting down\n\n"))
+
+	resp, err = http.Get(server.URL + "/events?stream=test")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	s := New()
+	s.CreateStream("test")
+
+	// A subscriber that never reads blocks the stream
+	stream := s.getStream("test")
+	sub := stream.addSubscriber("", nil)
+	for i := 0; i < cap(sub.connection)+1; i++ {
+		s.Publish("test", &Event{Data: []byte("test")})
+	}
+	s.handlers.Add(1)
+	defer s.handlers.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+	assert.Nil(t, s.getStream("test"))
+
+	// Unblock the stream so that it can exit
+	for range sub.connection {
+	}
+}
+
+func TestServerShutdownStalledSubscriber(t *testing.T) {
+	s := New()
+	s.ShutdownEvent = &Event{Retry: []byte("5000")}
+	s.CreateStream("test")
+
+	// A subscriber whose buffer is full blocks the final event
+	stream := s.getStream("test")
+	stream.addSubscriber("", nil)
+	for i := 0; i < DefaultSubscriberBufferSize; i++ {
+		s.Publish("test", &Event{Data: []byte("test")})
+	}
+	s.handlers.Add(1)
+	defer s.handlers.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- s.Shutdown(ctx)
+	}()
+
+	// The streams are not locked while shutting down
+	time.Sleep(time.Millisecond * 50)
+	exists := make(chan bool)
+	go func() {
+		exists <- s.StreamExists("test")
+	}()
+	select {
+	case <-exists:
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("streams locked during shutdown")
+	}
+
+	assert.Equal(t, context.DeadlineExceeded, <-shutdown)
+
+	// The stream stops once the shutdown timed out
+	require.Eventually(t, func() bool { return stream.getSubscriberCount() == 0 }, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,96 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+// State is the state of a client's connection
+type State int32
+
+const (
+	// StateClosed means the client is not subscribed, or gave up reconnecting
+	StateClosed State = iota
+	// StateConnecting means the client is establishing its first connection
+	StateConnecting
+	// StateOpen means the client is connected and receiving events
+	StateOpen
+	// StateReconnecting means the connection failed and the client is
+	// waiting to connect again
+	StateReconnecting
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateConnecting:
+		return "connecting"
+	case StateOpen:
+		return "open"
+	case StateReconnecting:
+		return "reconnecting"
+	}
+	return "unknown"
+}
+
+// StateCallback is called when the connection state of a client changes,
+// with the error that caused the change if any
+type StateCallback func(old, new State, err error)
+
+// State returns the state of the client's connection
+func (c *Client) State() State {
+	return State(atomic.LoadInt32(&c.state))
+}
+
+// OnStateChange specifies the function to run when the connection state
+// changes
+func (c *Client) OnStateChange(fn StateCallback) {
+	c.muState.Lock()
+	defer c.muState.Unlock()
+
+	c.statecb = fn
+}
+
+// setState moves the client to a new state, and runs the callbacks if the
+// state changed
+func (c *Client) setState(state State, err error) {
+	c.muState.Lock()
+	old := State(c.state)
+	atomic.StoreInt32(&c.state, int32(state))
+	c.Connected = state == StateOpen
+	statecb := c.statecb
+	c.muState.Unlock()
+
+	if old == state {
+		return
+	}
+
+	if statecb != nil {
+		statecb(old, state, err)
+	}
+	if state == StateOpen && c.connectedcb != nil {
+		c.connectedcb(c)
+	}
+	if old == StateOpen && c.disconnectcb != nil {
+		c.disconnectcb(c)
+	}
+}
+
+// reconnectNotify moves the client to the reconnecting state before waiting
+// to reconnect, then calls ReconnectNotify
+func (c *Client) reconnectNotify() backoff.Notify {
+	return func(err error, next time.Duration) {
+		c.setState(StateReconnecting, err)
+
+		if c.ReconnectNotify != nil {
+			c.ReconnectNotify(err, next)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+type stateChange struct {
+	old, new State
+	err      error
+}
+
+func waitState(t *testing.T, changes chan stateChange) stateChange {
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(time.Second * 5):
+		t.Fatal("state did not change")
+	}
+	return stateChange{}
+}
+
+func TestClientState(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	c := NewClient(server.URL)
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Millisecond * 10)
+	assert.Equal(t, StateClosed, c.State())
+
+	changes := make(chan stateChange, 10)
+	c.OnStateChange(func(old, new State, err error) {
+		changes <- stateChange{old, new, err}
+	})
+
+	events := make(chan *Event)
+	require.Nil(t, c.SubscribeChan("test", events))
+
+	assert.Equal(t, stateChange{StateClosed, StateConnecting, nil}, waitState(t, changes))
+	assert.Equal(t, stateChange{StateConnecting, StateOpen, nil}, waitState(t, changes))
+	assert.Equal(t, StateOpen, c.State())
+
+	server.CloseClientConnections()
+
+	change := waitState(t, changes)
+	assert.Equal(t, StateOpen, change.old)
+	assert.Equal(t, StateReconnecting, change.new)
+	assert.NotNil(t, change.err)
+
+	assert.Equal(t, stateChange{StateReconnecting, StateOpen, nil}, waitState(t, changes))
+
+	c.Unsubscribe(events)
+
+	assert.Equal(t, stateChange{StateOpen, StateClosed, nil}, waitState(t, changes))
+	assert.Equal(t, StateClosed, c.State())
+}
+
+func TestClientStatePermanentFailure(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.ReconnectStrategy = &backoff.StopBackOff{}
+
+	changes := make(chan stateChange, 10)
+	c.OnStateChange(func(old, new State, err error) {
+		changes <- stateChange{old, new, err}
+	})
+
+	err := c.Subscribe("missing", func(msg *Event) {})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, stateChange{StateClosed, StateConnecting, nil}, waitState(t, changes))
+	assert.Equal(t, stateChange{StateConnecting, StateClosed, err}, waitState(t, changes))
+}
+
+func TestClientStateCancelDuringReconnect(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	c := NewClient(server.URL)
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Minute)
+
+	changes := make(chan stateChange, 10)
+	c.OnStateChange(func(old, new State, err error) {
+		changes <- stateChange{old, new, err}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.SubscribeWithContext(ctx, "test", func(msg *Event) {})
+
+	assert.Equal(t, stateChange{StateClosed, StateConnecting, nil}, waitState(t, changes))
+	assert.Equal(t, stateChange{StateConnecting, StateOpen, nil}, waitState(t, changes))
+
+	server.CloseClientConnections()
+
+	change := waitState(t, changes)
+	assert.Equal(t, StateOpen, change.old)
+	assert.Equal(t, StateReconnecting, change.new)
+
+	cancel()
+
+	assert.Equal(t, stateChange{StateReconnecting, StateClosed, context.Canceled}, waitState(t, changes))
+	assert.Equal(t, StateClosed, c.State())
+}
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "reconnecting", StateReconnecting.String())
+	assert.Equal(t, "unknown", State(42).String())
+}
@@ -0,0 +1,99 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+// StatusError is returned when the server answers a subscription with a
+// status other than 200 OK. The body of the response is closed.
+type StatusError struct {
+	StatusCode int
+	Response   *http.Response
+	// Delay requested by the server in a Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("could not connect to stream: %s", http.StatusText(e.StatusCode))
+}
+
+// Temporary reports whether reconnecting may succeed. 204 No Content tells
+// the client to stop reconnecting, and client errors other than 408 Request
+// Timeout and 429 Too Many Requests are not expected to go away.
+func (e *StatusError) Temporary() bool {
+	switch {
+	case e.StatusCode == http.StatusNoContent:
+		return false
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return false
+	}
+	return true
+}
+
+// validateResponse checks the status and content type of a response as
+// required by the EventSource specification. Errors that reconnecting will
+// not fix are wrapped with backoff.Permanent.
+func (c *Client) validateResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+
+		err := &StatusError{
+			StatusCode: resp.StatusCode,
+			Response:   resp,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		// Credentials may be refreshed by the next attempts
+		refreshable := err.StatusCode == http.StatusUnauthorized && c.RequestModifier != nil
+		if !err.Temporary() && !refreshable {
+			return backoff.Permanent(err)
+		}
+		if err.RetryAfter > 0 {
+			atomic.StoreInt64(&c.retryAfter, int64(err.RetryAfter))
+		}
+		return err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/event-stream" {
+		resp.Body.Close()
+		return backoff.Permanent(fmt.Errorf("could not connect to stream: unexpected content type %q", resp.Header.Get("Content-Type")))
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header holding either a number of
+// seconds or a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// permanentError returns the error wrapped by backoff.Permanent, if any
+func permanentError(err error) error {
+	if permanent, ok := err.(*backoff.PermanentError); ok {
+		return permanent.Err
+	}
+	return err
+}
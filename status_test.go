@@ -0,0 +1,115 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+func TestClientPermanentStatus(t *testing.T) {
+	for _, status := range []int{http.StatusNoContent, http.StatusNotFound, http.StatusUnauthorized} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(status)
+		}))
+
+		c := NewClient(server.URL)
+		c.ReconnectStrategy = backoff.NewConstantBackOff(time.Millisecond)
+
+		err := c.Subscribe("test", func(msg *Event) {})
+		server.Close()
+
+		require.IsType(t, &StatusError{}, err)
+		assert.Equal(t, status, err.(*StatusError).StatusCode)
+		assert.Equal(t, status, err.(*StatusError).Response.StatusCode)
+		assert.Equal(t, 1, requests)
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+
+	s := New()
+	defer s.Close()
+	s.CreateStream("test")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		first := len(times) == 1
+		mu.Unlock()
+
+		if first {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Millisecond)
+
+	var notified error
+	c.ReconnectNotify = func(err error, d time.Duration) {
+		notified = err
+	}
+
+	events := make(chan *Event)
+	require.Nil(t, c.SubscribeChan("test", events))
+	defer c.Unsubscribe(events)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Equal(t, 2, len(times))
+	assert.True(t, times[1].Sub(times[0]) >= time.Second)
+
+	require.IsType(t, &StatusError{}, notified)
+	assert.Equal(t, time.Second, notified.(*StatusError).RetryAfter)
+}
+
+func TestClientInvalidContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.ReconnectStrategy = backoff.NewConstantBackOff(time.Millisecond)
+
+	err := c.Subscribe("test", func(msg *Event) {})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "text/html")
+}
+
+func TestStatusErrorTemporary(t *testing.T) {
+	assert.False(t, (&StatusError{StatusCode: http.StatusNoContent}).Temporary())
+	assert.False(t, (&StatusError{StatusCode: http.StatusForbidden}).Temporary())
+	assert.True(t, (&StatusError{StatusCode: http.StatusRequestTimeout}).Temporary())
+	assert.True(t, (&StatusError{StatusCode: http.StatusTooManyRequests}).Temporary())
+	assert.True(t, (&StatusError{StatusCode: http.StatusBadGateway}).Temporary())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, d > 58*time.Second && d <= time.Minute)
+}
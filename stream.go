@@ -5,9 +5,11 @@
 package sse
 
 import (
+	"context"
 	"net/url"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Stream ...
@@ -16,18 +18,39 @@ type Stream struct {
 	event           chan *Event
 	quit            chan struct{}
 	quitOnce        sync.Once
+	shutdown        chan *Event
 	register        chan *Subscriber
 	deregister      chan *Subscriber
 	subscribers     []*Subscriber
-	Eventlog        EventLog
+	Eventlog        EventStore
+	sharedLog       bool
+	unsubscribe     func()
+	muUnsubscribe   sync.Mutex
+	metrics         Metrics
 	subscriberCount int32
 	// Enables replaying of eventlog to newly added subscribers
 	AutoReplay   bool
 	isAutoStream bool
+	// Specifies what happens when a subscriber's buffer is full
+	SlowSubscriberPolicy SlowSubscriberPolicy
+	// Number of events queued for each subscriber, defaults to DefaultSubscriberBufferSize
+	SubscriberBufferSize int
+	// Interval between keepalive comments sent to idle subscribers, disabled when zero
+	HeartbeatInterval time.Duration
+	// Assigns ids to published events. When nil, the event store assigns
+	// sequential ids and ids set by the publisher are discarded
+	IDGenerator IDGenerator
+	// Decides whether an event is sent to a subscriber
+	Filter FilterFunc
 
 	// Specifies the function to run when client subscribe or un-subscribe
 	OnSubscribe   func(streamID string, sub *Subscriber)
 	OnUnsubscribe func(streamID string, sub *Subscriber)
+	// Specifies the function to run when the slow subscriber policy fires
+	OnSlowSubscriber func(streamID string, sub *Subscriber, policy SlowSubscriberPolicy)
+	// Specifies the function to run when the event store or the broker
+	// subscription fails
+	OnError func(streamID string, err error)
 }
 
 // newStream returns a new stream
@@ -41,7 +64,9 @@ func newStream(id string, buffSize int, replay, isAutoStream bool, onSubscribe,
 		deregister:    make(chan *Subscriber),
 		event:         make(chan *Event, buffSize),
 		quit:          make(chan struct{}),
-		Eventlog:      make(EventLog, 0),
+		shutdown:      make(chan *Event),
+		Eventlog:      NewEventLog(),
+		metrics:       nopMetrics{},
 		OnSubscribe:   onSubscribe,
 		OnUnsubscribe: onUnsubscribe,
 	}
@@ -54,8 +79,9 @@ func (str *Stream) run() {
 			// Add new subscriber
 			case subscriber := <-str.register:
 				str.subscribers = append(str.subscribers, subscriber)
-				if str.AutoReplay {
-					str.Eventlog.Replay(subscriber)
+				str.metrics.SubscriberAdded(str.ID)
+				if str.AutoReplay && !subscriber.skipReplay {
+					str.replay(subscriber)
 				}
 
 			// Remove closed subscriber
@@ -71,29 +97,215 @@ func (str *Stream) run() {
 
 			// Publish event to subscribers
 			case event := <-str.event:
-				if str.AutoReplay {
-					str.Eventlog.Add(event)
-				}
-				for i := range str.subscribers {
-					str.subscribers[i].connection <- event
+				str.metrics.BufferedEvents(str.ID, len(str.event))
+				str.publish(event)
+
+			// Flush pending events and say goodbye if the server shuts down
+			case final := <-str.shutdown:
+				str.flush()
+				if final != nil {
+					ev := *final
+					ev.timestamp = time.Now()
+					for i := range str.subscribers {
+						str.deliver(str.subscribers[i], &ev)
+					}
 				}
+				str.close()
+				str.stop()
+				return
 
 			// Shutdown if the server closes
 			case <-str.quit:
-				// remove connections
-				str.removeAllSubscribers()
+				str.stop()
 				return
 			}
 		}
 	}(str)
 }
 
+// SetEventLogLimits bounds the stream's event log. It has no effect when the
+// stream uses an event store other than EventLog.
+func (str *Stream) SetEventLogLimits(limits EventLogLimits) {
+	if log, ok := str.Eventlog.(*EventLog); ok {
+		log.SetLimits(limits)
+	}
+}
+
+// replay sends all logged events the subscriber has not seen yet, applying
+// the slow subscriber policy like for published events
+func (str *Stream) replay(sub *Subscriber) {
+	events, err := str.Eventlog.ReplayFrom(sub.eventid)
+	if err != nil {
+		str.reportError(err)
+		return
+	}
+
+	n := 0
+	for i := range events {
+		if !str.accepts(sub, events[i]) {
+			continue
+		}
+		if !str.deliver(sub, events[i]) {
+			if j := str.getSubIndex(sub); j != -1 {
+				str.removeSubscriber(j)
+			}
+			break
+		}
+		n++
+	}
+
+	str.metrics.EventsReplayed(str.ID, n)
+}
+
+// stop removes all connections and releases the stream's resources
+func (str *Stream) stop() {
+	str.removeAllSubscribers()
+
+	str.muUnsubscribe.Lock()
+	if str.unsubscribe != nil {
+		str.unsubscribe()
+		str.unsubscribe = nil
+	}
+	str.muUnsubscribe.Unlock()
+
+	if err := str.Eventlog.Close(); err != nil {
+		str.reportError(err)
+	}
+
+	str.metrics.StreamRemoved(str.ID)
+}
+
+// reportError passes an error of the event store to OnError
+func (str *Stream) reportError(err error) {
+	if str.OnError != nil {
+		go str.OnError(str.ID, err)
+	}
+}
+
+// setUnsubscribe sets the function ending the stream's broker subscription.
+// It is called right away if the stream is already closed.
+func (str *Stream) setUnsubscribe(fn func()) {
+	str.muUnsubscribe.Lock()
+	defer str.muUnsubscribe.Unlock()
+
+	select {
+	case <-str.quit:
+		fn()
+	default:
+		str.unsubscribe = fn
+	}
+}
+
+// receive queues an event delivered by the server's broker
+func (str *Stream) receive(ev *Event) {
+	select {
+	case str.event <- ev:
+	case <-str.quit:
+	}
+}
+
+// publish logs an event and queues it for every subscriber
+func (str *Stream) publish(event *Event) {
+	// Events of a shared log were logged by the broker
+	if !str.sharedLog {
+		if str.IDGenerator != nil && event.hasContent() {
+			event.ID = str.IDGenerator(event)
+		} else if str.AutoReplay {
+			event.ID = nil
+		}
+		if str.AutoReplay {
+			if err := str.Eventlog.Append(event); err != nil {
+				str.reportError(err)
+			}
+		}
+	}
+	str.metrics.EventPublished(str.ID)
+
+	for i := 0; i < len(str.subscribers); i++ {
+		if !str.accepts(str.subscribers[i], event) {
+			continue
+		}
+		if !str.deliver(str.subscribers[i], event) {
+			str.removeSubscriber(i)
+			i--
+		}
+	}
+}
+
+// flush publishes the events still buffered by the stream
+func (str *Stream) flush() {
+	for {
+		select {
+		case event := <-str.event:
+			str.publish(event)
+		default:
+			return
+		}
+	}
+}
+
+// deliver queues an event for a subscriber, applying the slow subscriber
+// policy when its buffer is full. It returns false if the subscriber must be
+// disconnected. A blocked delivery is abandoned when the stream is closed.
+func (str *Stream) deliver(sub *Subscriber, ev *Event) bool {
+	if str.SlowSubscriberPolicy == SlowSubscriberBlock {
+		select {
+		case sub.connection <- ev:
+		case <-str.quit:
+		}
+		return true
+	}
+
+	select {
+	case sub.connection <- ev:
+		return true
+	default:
+	}
+
+	if str.OnSlowSubscriber != nil {
+		go str.OnSlowSubscriber(str.ID, sub, str.SlowSubscriberPolicy)
+	}
+
+	switch str.SlowSubscriberPolicy {
+	case SlowSubscriberDropOldest:
+		select {
+		case <-sub.connection:
+		default:
+		}
+		select {
+		case sub.connection <- ev:
+		default:
+		}
+	case SlowSubscriberDisconnect:
+		for i := sub.drain(); i > 0; i-- {
+			str.metrics.EventDropped(str.ID)
+		}
+		sub.connection <- &Event{Comment: []byte("disconnected: subscriber too slow")}
+	}
+
+	str.metrics.EventDropped(str.ID)
+
+	return str.SlowSubscriberPolicy != SlowSubscriberDisconnect
+}
+
 func (str *Stream) close() {
 	str.quitOnce.Do(func() {
 		close(str.quit)
 	})
 }
 
+// gracefulClose asks the stream to flush its pending events and to send the
+// final event to its subscribers before closing. The stream is closed
+// immediately if the context expires first.
+func (str *Stream) gracefulClose(ctx context.Context, final *Event) {
+	select {
+	case str.shutdown <- final:
+	case <-str.quit:
+	case <-ctx.Done():
+		str.close()
+	}
+}
+
 func (str *Stream) getSubIndex(sub *Subscriber) int {
 	for i := range str.subscribers {
 		if str.subscribers[i] == sub {
@@ -104,20 +316,39 @@ func (str *Stream) getSubIndex(sub *Subscriber) int {
 }
 
 // addSubscriber will create a new subscriber on a stream
-func (str *Stream) addSubscriber(eventid int, url *url.URL) *Subscriber {
-	atomic.AddInt32(&str.subscriberCount, 1)
-	sub := &Subscriber{
+func (str *Stream) addSubscriber(eventid string, url *url.URL) *Subscriber {
+	return str.addSubscriberWithInfo(eventid, url, SubscriberInfo{})
+}
+
+// addSubscriberWithInfo will create a new subscriber with the given identity on a stream
+func (str *Stream) addSubscriberWithInfo(eventid string, url *url.URL, info SubscriberInfo) *Subscriber {
+	return str.subscribe(&Subscriber{
 		eventid:    eventid,
-		quit:       str.deregister,
-		connection: make(chan *Event, 64),
 		URL:        url,
-	}
+		Info:       info,
+		EventTypes: eventTypes(url),
+	})
+}
+
+// subscribe registers a subscriber with the stream
+func (str *Stream) subscribe(sub *Subscriber) *Subscriber {
+	atomic.AddInt32(&str.subscriberCount, 1)
+	sub.quit = str.deregister
+	sub.streamQuit = str.quit
+	sub.connection = make(chan *Event, str.subscriberBufferSize())
 
 	if str.isAutoStream {
 		sub.removed = make(chan struct{}, 1)
 	}
 
-	str.register <- sub
+	select {
+	case str.register <- sub:
+	case <-str.quit:
+		// The stream is closed, there is nothing to subscribe to
+		atomic.AddInt32(&str.subscriberCount, -1)
+		close(sub.connection)
+		return sub
+	}
 
 	if str.OnSubscribe != nil {
 		go str.OnSubscribe(str.ID, sub)
@@ -126,8 +357,16 @@ func (str *Stream) addSubscriber(eventid int, url *url.URL) *Subscriber {
 	return sub
 }
 
+func (str *Stream) subscriberBufferSize() int {
+	if str.SubscriberBufferSize > 0 {
+		return str.SubscriberBufferSize
+	}
+	return DefaultSubscriberBufferSize
+}
+
 func (str *Stream) removeSubscriber(i int) {
 	atomic.AddInt32(&str.subscriberCount, -1)
+	str.metrics.SubscriberRemoved(str.ID)
 	close(str.subscribers[i].connection)
 	if str.subscribers[i].removed != nil {
 		str.subscribers[i].removed <- struct{}{}
@@ -138,6 +377,7 @@ func (str *Stream) removeSubscriber(i int) {
 
 func (str *Stream) removeAllSubscribers() {
 	for i := 0; i < len(str.subscribers); i++ {
+		str.metrics.SubscriberRemoved(str.ID)
 		close(str.subscribers[i].connection)
 		if str.subscribers[i].removed != nil {
 			str.subscribers[i].removed <- struct{}{}
@@ -5,6 +5,7 @@
 package sse
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -21,7 +22,7 @@ func TestStreamAddSubscriber(t *testing.T) {
 	defer s.close()
 
 	s.event <- &Event{Data: []byte("test")}
-	sub := s.addSubscriber(0, nil)
+	sub := s.addSubscriber("", nil)
 
 	assert.Equal(t, 1, s.getSubscriberCount())
 
@@ -38,7 +39,7 @@ func TestStreamRemoveSubscriber(t *testing.T) {
 	s.run()
 	defer s.close()
 
-	sub := s.addSubscriber(0, nil)
+	sub := s.addSubscriber("", nil)
 	time.Sleep(time.Millisecond * 100)
 	s.deregister <- sub
 	time.Sleep(time.Millisecond * 100)
@@ -51,7 +52,7 @@ func TestStreamSubscriberClose(t *testing.T) {
 	s.run()
 	defer s.close()
 
-	sub := s.addSubscriber(0, nil)
+	sub := s.addSubscriber("", nil)
 	sub.close()
 	time.Sleep(time.Millisecond * 100)
 
@@ -66,7 +67,7 @@ func TestStreamDisableAutoReplay(t *testing.T) {
 	s.AutoReplay = false
 	s.event <- &Event{Data: []byte("test")}
 	time.Sleep(time.Millisecond * 100)
-	sub := s.addSubscriber(0, nil)
+	sub := s.addSubscriber("", nil)
 
 	assert.Equal(t, 0, len(sub.connection))
 }
@@ -78,7 +79,7 @@ func TestStreamMultipleSubscribers(t *testing.T) {
 	s.run()
 
 	for i := 0; i < 10; i++ {
-		subs = append(subs, s.addSubscriber(0, nil))
+		subs = append(subs, s.addSubscriber("", nil))
 	}
 
 	// Wait for all subscribers to be added
@@ -98,3 +99,101 @@ func TestStreamMultipleSubscribers(t *testing.T) {
 	assert.Equal(t, 0, s.getSubscriberCount())
 
 }
+
+func TestStreamSlowSubscriberDropNewest(t *testing.T) {
+	s := newStream("test", 1024, false, false, nil, nil)
+	s.SlowSubscriberPolicy = SlowSubscriberDropNewest
+	s.SubscriberBufferSize = 2
+
+	fired := make(chan SlowSubscriberPolicy, 10)
+	s.OnSlowSubscriber = func(streamID string, sub *Subscriber, policy SlowSubscriberPolicy) {
+		fired <- policy
+	}
+
+	s.run()
+	defer s.close()
+
+	sub := s.addSubscriber("", nil)
+	for i := 1; i <= 3; i++ {
+		s.event <- &Event{Data: []byte("test " + strconv.Itoa(i))}
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	require.Equal(t, 2, len(sub.connection))
+	assert.Equal(t, []byte("test 1"), (<-sub.connection).Data)
+	assert.Equal(t, []byte("test 2"), (<-sub.connection).Data)
+	assert.Equal(t, SlowSubscriberDropNewest, <-fired)
+}
+
+func TestStreamSlowSubscriberDropOldest(t *testing.T) {
+	s := newStream("test", 1024, false, false, nil, nil)
+	s.SlowSubscriberPolicy = SlowSubscriberDropOldest
+	s.SubscriberBufferSize = 2
+	s.run()
+	defer s.close()
+
+	sub := s.addSubscriber("", nil)
+	for i := 1; i <= 3; i++ {
+		s.event <- &Event{Data: []byte("test " + strconv.Itoa(i))}
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	require.Equal(t, 2, len(sub.connection))
+	assert.Equal(t, []byte("test 2"), (<-sub.connection).Data)
+	assert.Equal(t, []byte("test 3"), (<-sub.connection).Data)
+}
+
+func TestStreamSlowSubscriberDisconnect(t *testing.T) {
+	s := newStream("test", 1024, false, false, nil, nil)
+	s.SlowSubscriberPolicy = SlowSubscriberDisconnect
+	s.SubscriberBufferSize = 2
+	s.run()
+	defer s.close()
+
+	slow := s.addSubscriber("", nil)
+	fast := s.addSubscriber("", nil)
+
+	for i := 1; i <= 3; i++ {
+		s.event <- &Event{Data: []byte("test " + strconv.Itoa(i))}
+		_, err := wait(fast.connection, time.Second)
+		require.Nil(t, err)
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	assert.Equal(t, 1, s.getSubscriberCount())
+
+	ev, ok := <-slow.connection
+	require.True(t, ok)
+	assert.NotEmpty(t, ev.Comment)
+
+	_, ok = <-slow.connection
+	assert.False(t, ok)
+}
+
+func TestStreamSlowSubscriberReplay(t *testing.T) {
+	s := newStream("test", 1024, true, false, nil, nil)
+	s.SlowSubscriberPolicy = SlowSubscriberDisconnect
+	s.SubscriberBufferSize = 2
+	s.run()
+	defer s.close()
+
+	fast := s.addSubscriber("", nil)
+	for i := 1; i <= 5; i++ {
+		s.event <- &Event{Data: []byte("test " + strconv.Itoa(i))}
+		<-fast.connection
+	}
+
+	// The replay does not fit the buffer of a subscriber that does not read
+	slow := s.addSubscriber("", nil)
+
+	s.event <- &Event{Data: []byte("test 6")}
+	ev, err := waitEvent(fast.connection, time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("test 6"), ev.Data)
+
+	var last *Event
+	for ev := range slow.connection {
+		last = ev
+	}
+	assert.Equal(t, []byte("disconnected: subscriber too slow"), last.Comment)
+}
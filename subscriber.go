@@ -6,19 +6,63 @@ package sse
 
 import "net/url"
 
+// DefaultSubscriberBufferSize is the number of events queued for a subscriber
+const DefaultSubscriberBufferSize = 64
+
+// SlowSubscriberPolicy decides what happens to an event when a subscriber's
+// buffer is full
+type SlowSubscriberPolicy int
+
+const (
+	// SlowSubscriberBlock blocks the stream until the subscriber has room
+	SlowSubscriberBlock SlowSubscriberPolicy = iota
+	// SlowSubscriberDropNewest drops the event that does not fit the buffer
+	SlowSubscriberDropNewest
+	// SlowSubscriberDropOldest drops the oldest queued event to make room
+	SlowSubscriberDropOldest
+	// SlowSubscriberDisconnect discards the queued events and disconnects the
+	// subscriber with a final comment
+	SlowSubscriberDisconnect
+)
+
 // Subscriber ...
 type Subscriber struct {
 	quit       chan *Subscriber
+	streamQuit chan struct{}
 	connection chan *Event
 	removed    chan struct{}
-	eventid    int
+	eventid    string
+	skipReplay bool
 	URL        *url.URL
+	// Info holds the identity returned when the subscriber was authorized
+	Info SubscriberInfo
+	// EventTypes lists the only event types sent to the subscriber, taken
+	// from the url's event query parameter. All events are sent when empty.
+	EventTypes []string
 }
 
 // Close will let the stream know that the clients connection has terminated
 func (s *Subscriber) close() {
-	s.quit <- s
+	select {
+	case s.quit <- s:
+	case <-s.streamQuit:
+		// The stream has already removed all of its subscribers
+		return
+	}
 	if s.removed != nil {
 		<-s.removed
 	}
 }
+
+// drain discards all events queued for the subscriber and returns their number
+func (s *Subscriber) drain() int {
+	n := 0
+	for {
+		select {
+		case <-s.connection:
+			n++
+		default:
+			return n
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import "context"
+
+// Names of the spans started by the server and client
+const (
+	SpanPublish = "sse.publish"
+	SpanDeliver = "sse.deliver"
+	SpanReceive = "sse.receive"
+)
+
+// Span is a unit of traced work
+type Span interface {
+	// End completes the span
+	End()
+}
+
+// Tracer bridges the server and client to a tracing library such as
+// OpenTelemetry. Trace contexts travel with events as opaque bytes, e.g. a
+// W3C traceparent. Implementations must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span for an event of the given stream, as a child of
+	// the span held by ctx
+	Start(ctx context.Context, name, streamID string) (context.Context, Span)
+	// Inject encodes the trace context held by ctx
+	Inject(ctx context.Context) []byte
+	// Extract returns a copy of ctx holding the encoded trace context
+	Extract(ctx context.Context, traceContext []byte) context.Context
+}
+
+// nopSpan is returned when no tracer is set
+type nopSpan struct{}
+
+func (nopSpan) End() {}
+
+// tracePublish starts the publish span of an event and carries its trace
+// context on the event
+func (s *Server) tracePublish(ctx context.Context, id string, event *Event) Span {
+	if s.Tracer == nil {
+		return nopSpan{}
+	}
+
+	ctx, span := s.Tracer.Start(ctx, SpanPublish, id)
+	event.TraceContext = s.Tracer.Inject(ctx)
+
+	return span
+}
+
+// traceDelivery starts the span of an event's delivery to a subscriber, as a
+// child of its publish span. The returned copy of the event carries the
+// trace context of the delivery span.
+func (s *Server) traceDelivery(ctx context.Context, streamID string, ev *Event) (*Event, Span) {
+	if s.Tracer == nil {
+		return ev, nopSpan{}
+	}
+
+	ctx, span := s.Tracer.Start(s.Tracer.Extract(ctx, ev.TraceContext), SpanDeliver, streamID)
+
+	cp := *ev
+	cp.TraceContext = s.Tracer.Inject(ctx)
+
+	return &cp, span
+}
+
+// traceReceive calls handler within a consumer span, as a child of the span
+// that delivered the event. The event then carries the trace context of the
+// consumer span.
+func (c *Client) traceReceive(handler func(msg *Event), streamID string, msg *Event) {
+	if c.Tracer == nil {
+		handler(msg)
+		return
+	}
+
+	ctx, span := c.Tracer.Start(c.Tracer.Extract(context.Background(), msg.TraceContext), SpanReceive, streamID)
+	defer span.End()
+
+	msg.TraceContext = c.Tracer.Inject(ctx)
+	handler(msg)
+}
@@ -0,0 +1,151 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+// fakeSpan records a started span and its parent
+type fakeSpan struct {
+	id, parent, name, stream string
+	ended                    bool
+	tracer                   *fakeTracer
+}
+
+func (s *fakeSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.ended = true
+}
+
+// fakeTracer records spans, the trace context is the id of the current span
+type fakeTracer struct {
+	prefix string
+	spans  []*fakeSpan
+	mu     sync.Mutex
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name, streamID string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parent, _ := ctx.Value(spanKey{}).(string)
+	span := &fakeSpan{
+		id:     fmt.Sprintf("%s%d", t.prefix, len(t.spans)),
+		parent: parent,
+		name:   name,
+		stream: streamID,
+		tracer: t,
+	}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span.id), span
+}
+
+func (t *fakeTracer) Inject(ctx context.Context) []byte {
+	id, _ := ctx.Value(spanKey{}).(string)
+	return []byte(id)
+}
+
+func (t *fakeTracer) Extract(ctx context.Context, traceContext []byte) context.Context {
+	return context.WithValue(ctx, spanKey{}, string(traceContext))
+}
+
+func (t *fakeTracer) find(name string) *fakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, span := range t.spans {
+		if span.name == name {
+			cp := *span
+			return &cp
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	serverTracer := &fakeTracer{prefix: "server-"}
+	clientTracer := &fakeTracer{prefix: "client-"}
+
+	s := New()
+	s.Tracer = serverTracer
+	s.PropagateTraceContext = true
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	s.CreateStream("test")
+
+	c := NewClient(server.URL)
+	c.Tracer = clientTracer
+
+	events := make(chan *Event, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.SubscribeWithContext(ctx, "test", func(msg *Event) {
+		events <- msg
+	})
+
+	// wait for the subscriber before publishing
+	for s.getStream("test").getSubscriberCount() == 0 {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	parent := context.WithValue(context.Background(), spanKey{}, "root")
+	s.PublishWithContext(parent, "test", &Event{Data: []byte("test")})
+
+	msg, err := waitEvent(events, time.Second)
+	require.Nil(t, err)
+
+	publish := serverTracer.find(SpanPublish)
+	require.NotNil(t, publish)
+	assert.Equal(t, "root", publish.parent)
+	assert.Equal(t, "test", publish.stream)
+	assert.True(t, publish.ended)
+
+	deliver := serverTracer.find(SpanDeliver)
+	require.NotNil(t, deliver)
+	assert.Equal(t, publish.id, deliver.parent)
+
+	receive := clientTracer.find(SpanReceive)
+	require.NotNil(t, receive)
+	assert.Equal(t, deliver.id, receive.parent)
+	assert.Equal(t, "test", receive.stream)
+	assert.Equal(t, []byte(receive.id), msg.TraceContext)
+}
+
+func TestTraceContextField(t *testing.T) {
+	s := New()
+	s.Tracer = &fakeTracer{}
+	defer s.Close()
+
+	ev := &Event{Data: []byte("test")}
+
+	rec := httptest.NewRecorder()
+	s.writeEvent(rec, ev, []byte("1"), "")
+	assert.Equal(t, "id: 1\ndata: test\n\n", rec.Body.String())
+
+	s.CreateStream("test")
+	s.Publish("test", ev)
+	assert.Equal(t, []byte("0"), ev.TraceContext)
+
+	s.PropagateTraceContext = true
+	rec = httptest.NewRecorder()
+	s.writeEvent(rec, ev, []byte("1"), "")
+	assert.Equal(t, "id: 1\ndata: test\ntrace: 0\n\n", rec.Body.String())
+}